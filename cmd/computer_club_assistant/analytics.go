@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/internal/myparser"
+	"github.com/apartapatia/computer_club_assistant/pkg/analytics"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+const analyticsCommand = "analytics"
+
+// runAnalytics processes the log and prints the occupancy of the tables,
+// the queue and the refused clients over the day.
+func runAnalytics(args []string) int {
+	fs := flag.NewFlagSet(analyticsCommand, flag.ExitOnError)
+	step := fs.Duration("step", time.Hour, "length of a heatmap column, e.g. 1h or 15m")
+	formatName := fs.String("format", string(myparser.FormatAuto), "input format: auto, text, json or yaml")
+	outputName := fs.String("output", string(report.FormatText), "output format: text or csv")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: computer_club_assistant analytics [-step 1h] [-format auto|text|json|yaml] [-output text|csv] <file_name>")
+		return 1
+	}
+
+	format, err := myparser.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Printf("Unknown input format %s. Use auto, text, json or yaml.\n", *formatName)
+		return 1
+	}
+
+	output, err := report.ParseFormat(*outputName)
+	if err != nil || output == report.FormatJSON {
+		fmt.Printf("Unknown output format %s. Use text or csv.\n", *outputName)
+		return 1
+	}
+
+	file, filePath, err := openInput(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+
+	if format == myparser.FormatAuto {
+		format = myparser.FormatFromPath(filePath)
+	}
+
+	handler, err := load(file, format, options{format: format})
+	if err != nil {
+		printError(err)
+		return 1
+	}
+
+	days, err := analytics.Build(handler.Report(), handler.Club.MaxTables, *step, handler.StateAt)
+	if err != nil {
+		fmt.Printf("Invalid step %s. Use whole minutes, e.g. 1h or 15m.\n", *step)
+		return 1
+	}
+
+	if output == report.FormatCSV {
+		err = analytics.WriteCSV(os.Stdout, days)
+	} else {
+		err = analytics.WriteText(os.Stdout, days)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
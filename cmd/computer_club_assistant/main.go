@@ -1,56 +1,225 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
+	bolt "go.etcd.io/bbolt"
+
 	"github.com/apartapatia/computer_club_assistant/internal/myparser"
 	"github.com/apartapatia/computer_club_assistant/pkg/client"
 	"github.com/apartapatia/computer_club_assistant/pkg/handlers"
+	"github.com/apartapatia/computer_club_assistant/pkg/queue"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
 	"github.com/apartapatia/computer_club_assistant/pkg/table"
 )
 
+const (
+	configsDir = "configs"
+	stdinName  = "-"
+)
+
+type options struct {
+	allErrors bool
+	format    myparser.Format
+	output    report.Format
+	clients   bool
+	store     *bolt.DB
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: computer_club_assistant <file_name>")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case serveCommand:
+			os.Exit(serve(os.Args[2:]))
+		case stateCommand:
+			os.Exit(state(os.Args[2:]))
+		case analyticsCommand:
+			os.Exit(runAnalytics(os.Args[2:]))
+		}
+	}
+
+	var opts options
+
+	allErrors := flag.Bool("all-errors", false, "report every invalid line instead of the first one")
+	formatName := flag.String("format", string(myparser.FormatAuto), "input format: auto, text, json or yaml")
+	outputName := flag.String("output", string(report.FormatText), "output format: text, json or csv")
+	clients := flag.Bool("clients", false, "end the text report with the statistics of every client")
+	flag.Parse()
+
+	format, err := myparser.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Printf("Unknown input format %s. Use auto, text, json or yaml.\n", *formatName)
+		os.Exit(1)
+	}
+
+	output, err := report.ParseFormat(*outputName)
+	if err != nil {
+		fmt.Printf("Unknown output format %s. Use text, json or csv.\n", *outputName)
+		os.Exit(1)
+	}
+
+	opts.allErrors = *allErrors
+	opts.format = format
+	opts.output = output
+	opts.clients = *clients
+
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: computer_club_assistant [-all-errors] [-format auto|text|json|yaml] [-output text|json|csv] [-clients] <file_name|-> [file_name...]")
 		fmt.Println("🪟 For Windows: ./computer_club_assistant.exe <file_name>")
 		fmt.Println("🐧 For Linux: ./computer_club_assistant <file_name>")
+		fmt.Println("📥 From stdin: cat <file_name> | ./computer_club_assistant -")
+		fmt.Println("🌐 HTTP server: ./computer_club_assistant serve [-addr :8080] <file_name>")
+		fmt.Println("🕑 State at a moment: ./computer_club_assistant state -at 14:30 <file_name>")
+		fmt.Println("📊 Occupancy heatmap: ./computer_club_assistant analytics [-step 15m] <file_name>")
 		os.Exit(1)
 	}
 
-	fileName := os.Args[1]
-	filePath := filepath.Join("configs/" + fileName)
+	writer := report.NewWriter(opts.output)
+	if tw, ok := writer.(*report.TextWriter); ok {
+		tw.Clients = opts.clients
+	}
+
+	exitCode := 0
+	for i, name := range flag.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		res, err := processFile(name, opts)
+		if err != nil {
+			printError(err)
+			exitCode = 1
+			continue
+		}
+
+		if err := writer.Write(os.Stdout, res); err != nil {
+			fmt.Println(err)
+			exitCode = 1
+		}
+	}
+
+	os.Exit(exitCode)
+}
+
+// openInput opens name as given, falling back to the configs directory for
+// bare file names. "-" stands for standard input. The returned path is used
+// to detect the input format.
+func openInput(name string) (io.ReadCloser, string, error) {
+	if name == stdinName {
+		return io.NopCloser(os.Stdin), "", nil
+	}
+
+	filePath := name
+	if _, err := os.Stat(filePath); os.IsNotExist(err) && !filepath.IsAbs(name) {
+		filePath = filepath.Join(configsDir, name)
+	}
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		fmt.Printf("File %s not found. Please check the file path and try again.\n", filePath)
-		os.Exit(1)
+		return nil, "", fmt.Errorf("File %s not found. Please check the file path and try again.", name)
 	}
 
 	file, err := os.Open(filePath)
+	return file, filePath, err
+}
+
+func processFile(name string, opts options) (*report.Report, error) {
+	file, filePath, err := openInput(name)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 	defer file.Close()
 
-	pars := myparser.NewFileParser(file)
+	format := opts.format
+	if format == myparser.FormatAuto {
+		format = myparser.FormatFromPath(filePath)
+	}
+
+	return process(file, format, opts)
+}
+
+func process(r io.Reader, format myparser.Format, opts options) (*report.Report, error) {
+	handler, err := load(r, format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler.Report(), nil
+}
+
+// load parses the club and its events and wires them to the repositories:
+// in memory, or persisted in the store when one is given.
+func load(r io.Reader, format myparser.Format, opts options) (*handlers.CommandHandler, error) {
+	pars := myparser.NewParser(r, format)
+	if opts.allErrors {
+		pars.CollectAll()
+	}
+
 	clubInfo, err := pars.ReadClubInfo()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	managerInfo, err := pars.ReadManagerEvents(clubInfo)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
+	}
+
+	policy, err := queue.PolicyByName(clubInfo.Queue.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	billing, err := table.NewBillingStrategy(clubInfo.Billing.Strategy, clubInfo.Billing.Block, clubInfo.Billing.Grace)
+	if err != nil {
+		return nil, err
 	}
 
-	clients := client.NewMemoryRepo()
-	tables := table.NewMemoryRepo(clubInfo.MaxTables)
-	handler := handlers.NewCommandHandler(clubInfo, managerInfo, clients, tables)
+	waiting := queue.NewMemoryQueue(policy)
+	tariff := table.NewTariff(clubInfo.Tariffs)
+	discounts := table.NewDiscounts(clubInfo.Discounts)
 
-	res := handler.HandleCommands()
-	fmt.Println(res)
+	if opts.store == nil {
+		clients := client.NewMemoryRepo()
+		tables := table.NewMemoryRepo(clubInfo.MaxTables, billing, tariff, discounts, clubInfo.Categories)
+		return handlers.NewCommandHandler(clubInfo, managerInfo, clients, tables, waiting), nil
+	}
+
+	clients, err := client.NewBoltRepo(opts.store)
+	if err != nil {
+		return nil, err
+	}
+
+	tables, err := table.NewBoltRepo(opts.store, clubInfo.MaxTables, billing, tariff, discounts, clubInfo.Categories)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := client.NewAccountBoltRepo(opts.store)
+	if err != nil {
+		return nil, err
+	}
+
+	visits, err := client.NewVisitBoltRepo(opts.store)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := handlers.NewCommandHandler(clubInfo, managerInfo, clients, tables, waiting)
+	handler.Accounts = accounts
+	handler.Visits = visits
+	return handler, nil
+}
+
+func printError(err error) {
+	var parseErr *myparser.ParseError
+	if errors.As(err, &parseErr) && !errors.As(err, new(myparser.ParseErrors)) {
+		fmt.Println(parseErr.Raw)
+	} else {
+		fmt.Println(err)
+	}
 }
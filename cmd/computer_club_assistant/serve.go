@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/apartapatia/computer_club_assistant/internal/myparser"
+	"github.com/apartapatia/computer_club_assistant/pkg/handlers"
+	"github.com/apartapatia/computer_club_assistant/pkg/journal"
+)
+
+const serveCommand = "serve"
+
+// serve runs the club configured in the given file as an HTTP server. Events
+// already present in the file are processed before the server starts.
+func serve(args []string) int {
+	fs := flag.NewFlagSet(serveCommand, flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	formatName := fs.String("format", string(myparser.FormatAuto), "input format: auto, text, json or yaml")
+	storePath := fs.String("store", "", "file to keep the clients and tables in across restarts")
+	journalPath := fs.String("journal", "", "file to journal the events to and recover them from")
+	historyDays := fs.Int("history-days", 7, "closed working days to keep the state history of for /state, 0 for all")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: computer_club_assistant serve [-addr :8080] [-format auto|text|json|yaml] [-history-days 7] [-store club.db | -journal club.journal] <file_name>")
+		return 1
+	}
+
+	if *storePath != "" && *journalPath != "" {
+		fmt.Println("Use either -store or -journal, not both.")
+		return 1
+	}
+
+	format, err := myparser.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Printf("Unknown input format %s. Use auto, text, json or yaml.\n", *formatName)
+		return 1
+	}
+
+	file, filePath, err := openInput(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+
+	if format == myparser.FormatAuto {
+		format = myparser.FormatFromPath(filePath)
+	}
+
+	opts := options{format: format}
+	if *storePath != "" {
+		db, err := bolt.Open(*storePath, 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer db.Close()
+		opts.store = db
+	}
+
+	handler, err := load(file, format, opts)
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	handler.HistoryDays = *historyDays
+
+	// A store that already holds the club has the events of the file in it.
+	if handler.Visits.Initialised() {
+		clients, tables := len(handler.Clients.GetAll()), len(handler.Tables.GetAll())
+		waiting := handler.RestoreQueue()
+		fmt.Printf("Recovered %d clients (%d waiting) and %d tables from %s\n", clients, waiting, tables, *storePath)
+		handler.Managers = nil
+	}
+
+	if *journalPath != "" {
+		j, err := recoverJournal(handler, *journalPath)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer j.Close()
+	}
+
+	replay(handler)
+	if err := handler.Visits.MarkInitialised(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	fmt.Printf("Serving the club on %s\n", *addr)
+	if err := http.ListenAndServe(*addr, handlers.NewServer(handler)); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// replay processes the events of the file before the club is served. An
+// event the club cannot process is reported and skipped.
+func replay(handler *handlers.CommandHandler) {
+	for _, m := range handler.Managers {
+		if _, err := handler.HandleEvent(m); err != nil {
+			fmt.Printf("Skipped event %s: %v\n", strings.TrimSuffix(m.String(), "\n"), err)
+		}
+	}
+	handler.Managers = nil
+}
+
+// recoverJournal replays the events journaled before a restart in place of
+// the events of the file and journals everything from then on.
+func recoverJournal(handler *handlers.CommandHandler, path string) (*journal.Journal, error) {
+	j, records, err := journal.NewJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) != 0 {
+		replayed, err := journal.Replay(records, handler)
+		if err != nil {
+			j.Close()
+			return nil, err
+		}
+
+		fmt.Printf("Replayed %d events from %s\n", replayed, path)
+		handler.Managers = nil
+	}
+
+	handler.Journal = j
+	return j, nil
+}
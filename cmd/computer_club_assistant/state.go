@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apartapatia/computer_club_assistant/internal/myparser"
+	"github.com/apartapatia/computer_club_assistant/pkg/handlers"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+const stateCommand = "state"
+
+// state processes the log and prints the tables, the queue and the revenue
+// at the given moment of the day.
+func state(args []string) int {
+	fs := flag.NewFlagSet(stateCommand, flag.ExitOnError)
+	at := fs.String("at", "", "moment to show, HH:MM or YYYY-MM-DD HH:MM")
+	formatName := fs.String("format", string(myparser.FormatAuto), "input format: auto, text, json or yaml")
+	outputName := fs.String("output", string(report.FormatText), "output format: text or json")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || *at == "" {
+		fmt.Println("Usage: computer_club_assistant state -at HH:MM [-format auto|text|json|yaml] [-output text|json] <file_name>")
+		return 1
+	}
+
+	format, err := myparser.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Printf("Unknown input format %s. Use auto, text, json or yaml.\n", *formatName)
+		return 1
+	}
+
+	output, err := report.ParseFormat(*outputName)
+	if err != nil || output == report.FormatCSV {
+		fmt.Printf("Unknown output format %s. Use text or json.\n", *outputName)
+		return 1
+	}
+
+	file, filePath, err := openInput(fs.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer file.Close()
+
+	if format == myparser.FormatAuto {
+		format = myparser.FormatFromPath(filePath)
+	}
+
+	handler, err := load(file, format, options{format: format})
+	if err != nil {
+		printError(err)
+		return 1
+	}
+	handler.Report()
+
+	moment, err := handlers.ParseStateTime(*at, handler.Club)
+	if err != nil {
+		fmt.Printf("Invalid time %s. Use HH:MM or YYYY-MM-DD HH:MM.\n", *at)
+		return 1
+	}
+
+	result, ok := handler.StateAt(moment)
+	if !ok {
+		fmt.Printf("Nothing happened in the club before %s.\n", *at)
+		return 1
+	}
+
+	if output == report.FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+
+	writeState(os.Stdout, result)
+	return 0
+}
+
+// writeState prints the moment, then a line per table with its client and
+// the start of the session, the queue and the revenue collected so far.
+func writeState(w io.Writer, s handlers.State) {
+	fmt.Fprintln(w, handlers.FormatStateTime(s.Time))
+
+	for _, t := range s.Tables {
+		if t.Client == "" {
+			fmt.Fprintf(w, "%d\n", t.TableID)
+		} else {
+			fmt.Fprintf(w, "%d %s %s\n", t.TableID, t.Client, t.Since)
+		}
+	}
+
+	clients := make([]string, 0, len(s.Queue))
+	for _, q := range s.Queue {
+		clients = append(clients, q.Client)
+	}
+
+	fmt.Fprintln(w, strings.TrimSpace("queue: "+strings.Join(clients, " ")))
+	fmt.Fprintf(w, "revenue: %d\n", s.Revenue)
+}
@@ -1,3 +1,10 @@
 module github.com/apartapatia/computer_club_assistant
 
 go 1.20
+
+require (
+	go.etcd.io/bbolt v1.3.8
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,639 @@
+package myparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/queue"
+	"github.com/apartapatia/computer_club_assistant/pkg/table"
+)
+
+type queueDocument struct {
+	Capacity        int            `json:"capacity" yaml:"capacity"`
+	Policy          string         `json:"policy" yaml:"policy"`
+	VIP             []string       `json:"vip" yaml:"vip"`
+	ExpectedMinutes map[string]int `json:"expected_minutes" yaml:"expected_minutes"`
+}
+
+type billingDocument struct {
+	Strategy     string `json:"strategy" yaml:"strategy"`
+	BlockMinutes int    `json:"block_minutes" yaml:"block_minutes"`
+	GraceMinutes int    `json:"grace_minutes" yaml:"grace_minutes"`
+}
+
+type tariffDocument struct {
+	Name  string `json:"name" yaml:"name"`
+	From  string `json:"from" yaml:"from"`
+	To    string `json:"to" yaml:"to"`
+	Price int    `json:"price" yaml:"price"`
+}
+
+type categoryDocument struct {
+	Name   string `json:"name" yaml:"name"`
+	Price  int    `json:"price" yaml:"price"`
+	Tables []int  `json:"tables" yaml:"tables"`
+}
+
+type membershipDocument struct {
+	Name    string   `json:"name" yaml:"name"`
+	Percent int      `json:"percent" yaml:"percent"`
+	Clients []string `json:"clients" yaml:"clients"`
+}
+
+type happyHourDocument struct {
+	Name    string `json:"name" yaml:"name"`
+	From    string `json:"from" yaml:"from"`
+	To      string `json:"to" yaml:"to"`
+	Percent int    `json:"percent" yaml:"percent"`
+}
+
+type bundleDocument struct {
+	Name  string `json:"name" yaml:"name"`
+	Hours int    `json:"hours" yaml:"hours"`
+	Paid  int    `json:"paid" yaml:"paid"`
+}
+
+type promoCodeDocument struct {
+	Code    string `json:"code" yaml:"code"`
+	Percent int    `json:"percent" yaml:"percent"`
+}
+
+type discountsDocument struct {
+	Memberships []membershipDocument `json:"memberships" yaml:"memberships"`
+	HappyHours  []happyHourDocument  `json:"happy_hours" yaml:"happy_hours"`
+	Bundles     []bundleDocument     `json:"bundles" yaml:"bundles"`
+	PromoCodes  []promoCodeDocument  `json:"promo_codes" yaml:"promo_codes"`
+}
+
+type packageDocument struct {
+	Name  string `json:"name" yaml:"name"`
+	From  string `json:"from" yaml:"from"`
+	To    string `json:"to" yaml:"to"`
+	Price int    `json:"price" yaml:"price"`
+}
+
+type clubDocument struct {
+	Tables     int                `json:"tables" yaml:"tables"`
+	Open       string             `json:"open" yaml:"open"`
+	Close      string             `json:"close" yaml:"close"`
+	Price      int                `json:"price" yaml:"price"`
+	Queue      queueDocument      `json:"queue" yaml:"queue"`
+	Billing    billingDocument    `json:"billing" yaml:"billing"`
+	Tariffs    []tariffDocument   `json:"tariffs" yaml:"tariffs"`
+	Categories []categoryDocument `json:"categories" yaml:"categories"`
+	Discounts  discountsDocument  `json:"discounts" yaml:"discounts"`
+	Packages   []packageDocument  `json:"packages" yaml:"packages"`
+
+	Reservations reservationsDocument `json:"reservations" yaml:"reservations"`
+	Pause        pauseDocument        `json:"pause" yaml:"pause"`
+}
+
+type eventDocument struct {
+	Time     string `json:"time" yaml:"time"`
+	ID       int    `json:"id" yaml:"id"`
+	Client   string `json:"client" yaml:"client"`
+	Table    int    `json:"table,omitempty" yaml:"table,omitempty"`
+	Category string `json:"category,omitempty" yaml:"category,omitempty"`
+	Start    string `json:"start,omitempty" yaml:"start,omitempty"`
+	Minutes  int    `json:"minutes,omitempty" yaml:"minutes,omitempty"`
+	Amount   int    `json:"amount,omitempty" yaml:"amount,omitempty"`
+	Promo    string `json:"promo,omitempty" yaml:"promo,omitempty"`
+	Package  string `json:"package,omitempty" yaml:"package,omitempty"`
+}
+
+type reservationsDocument struct {
+	GraceMinutes *int `json:"grace_minutes" yaml:"grace_minutes"`
+}
+
+type pauseDocument struct {
+	MaxMinutes *int `json:"max_minutes" yaml:"max_minutes"`
+}
+
+type document struct {
+	Club   clubDocument    `json:"club" yaml:"club"`
+	Events []eventDocument `json:"events" yaml:"events"`
+}
+
+// fields returns the event in the positional layout of the text format so
+// both parsers share the same validation.
+func (e eventDocument) fields() []string {
+	parts := []string{e.Time, strconv.Itoa(e.ID), e.Client}
+
+	if e.ID == club.IncomingClientBooked {
+		target := e.Category
+		if e.Table != 0 {
+			target = strconv.Itoa(e.Table)
+		}
+		return append(parts, target, e.Start, strconv.Itoa(e.Minutes))
+	}
+
+	if e.ID == club.IncomingClientToppedUp {
+		return append(parts, strconv.Itoa(e.Amount))
+	}
+
+	if e.ID == club.IncomingClientCome && e.Promo != "" {
+		return append(parts, e.Promo)
+	}
+
+	if e.ID == club.IncomingClientBoughtPackage && e.Package != "" {
+		return append(parts, e.Package)
+	}
+
+	if e.Table != 0 {
+		parts = append(parts, strconv.Itoa(e.Table))
+	}
+	return parts
+}
+
+// DocumentParser reads the club and its events from a JSON or YAML document.
+// Since a document has no meaningful line numbers, ParseError.Line holds the
+// 1-based position of the event in the events array and 0 for the club
+// section.
+type DocumentParser struct {
+	collector
+	reader io.Reader
+	format Format
+	doc    *document
+}
+
+func (dp *DocumentParser) decode() error {
+	if dp.doc != nil {
+		return nil
+	}
+
+	doc := &document{}
+
+	var err error
+	switch dp.format {
+	case FormatJSON:
+		err = json.NewDecoder(dp.reader).Decode(doc)
+	case FormatYAML:
+		err = yaml.NewDecoder(dp.reader).Decode(doc)
+	default:
+		err = fmt.Errorf("unsupported document format %q", dp.format)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrReadData, err)
+	}
+
+	dp.doc = doc
+	return nil
+}
+
+func (dp *DocumentParser) readPositiveInt(v int, field string) (int, error) {
+	raw := strconv.Itoa(v)
+	if _, err := dp.ParseInt(raw); err != nil {
+		return 0, dp.InvalidParse(newParseError(0, raw, 1, field, ReasonInvalidInt, err))
+	}
+	return v, nil
+}
+
+func (dp *DocumentParser) ReadClubInfo() (*club.Club, error) {
+	if err := dp.decode(); err != nil {
+		return nil, err
+	}
+
+	maxTables, err := dp.readPositiveInt(dp.doc.Club.Tables, "tables")
+	if err != nil {
+		return nil, err
+	}
+
+	raw := dp.doc.Club.Open + " " + dp.doc.Club.Close
+	workingTime, perr := parseWorkingTime(0, raw, dp.doc.Club.Open, dp.doc.Club.Close)
+	if perr != nil {
+		if err := dp.InvalidParse(perr); err != nil {
+			return nil, err
+		}
+	}
+
+	price, err := dp.readPositiveInt(dp.doc.Club.Price, "price")
+	if err != nil {
+		return nil, err
+	}
+
+	activeClub := club.NewClub(workingTime, price, maxTables)
+
+	activeClub.Queue, err = dp.readQueueConfig(dp.doc.Club.Queue)
+	if err != nil {
+		return nil, err
+	}
+
+	activeClub.Billing, err = dp.readBillingConfig(dp.doc.Club.Billing)
+	if err != nil {
+		return nil, err
+	}
+
+	activeClub.Tariffs, err = dp.readTariffs(dp.doc.Club.Tariffs)
+	if err != nil {
+		return nil, err
+	}
+
+	activeClub.Categories, err = dp.readCategories(dp.doc.Club.Categories, maxTables)
+	if err != nil {
+		return nil, err
+	}
+
+	activeClub.Discounts, err = dp.readDiscounts(dp.doc.Club.Discounts)
+	if err != nil {
+		return nil, err
+	}
+
+	activeClub.Packages, err = dp.readPackages(dp.doc.Club.Packages)
+	if err != nil {
+		return nil, err
+	}
+
+	if grace := dp.doc.Club.Reservations.GraceMinutes; grace != nil {
+		if *grace < 0 {
+			raw := strconv.Itoa(*grace)
+			if err := dp.InvalidParse(newParseError(0, raw, 1, "reservations.grace_minutes", ReasonInvalidInt, ErrParseInt)); err != nil {
+				return nil, err
+			}
+		} else {
+			activeClub.ReservationGrace = time.Duration(*grace) * time.Minute
+		}
+	}
+
+	if limit := dp.doc.Club.Pause.MaxMinutes; limit != nil {
+		if *limit < 0 {
+			raw := strconv.Itoa(*limit)
+			if err := dp.InvalidParse(newParseError(0, raw, 1, "pause.max_minutes", ReasonInvalidInt, ErrParseInt)); err != nil {
+				return nil, err
+			}
+		} else {
+			activeClub.MaxPause = time.Duration(*limit) * time.Minute
+		}
+	}
+
+	return activeClub, nil
+}
+
+func (dp *DocumentParser) readQueueConfig(doc queueDocument) (*club.QueueConfig, error) {
+	config := &club.QueueConfig{
+		Policy:           doc.Policy,
+		VIPClients:       make(map[string]bool),
+		ExpectedSessions: make(map[string]time.Duration),
+	}
+
+	if doc.Capacity < 0 {
+		raw := strconv.Itoa(doc.Capacity)
+		if err := dp.InvalidParse(newParseError(0, raw, 1, "queue.capacity", ReasonInvalidInt, ErrParseInt)); err != nil {
+			return nil, err
+		}
+	} else {
+		config.Capacity = doc.Capacity
+	}
+
+	if _, err := queue.PolicyByName(doc.Policy); err != nil {
+		if err := dp.InvalidParse(newParseError(0, doc.Policy, 1, "queue.policy", ReasonUnknownPolicy, err)); err != nil {
+			return nil, err
+		}
+		config.Policy = ""
+	}
+
+	for _, username := range doc.VIP {
+		config.VIPClients[username] = true
+	}
+
+	for username, minutes := range doc.ExpectedMinutes {
+		config.ExpectedSessions[username] = time.Duration(minutes) * time.Minute
+	}
+
+	return config, nil
+}
+
+func (dp *DocumentParser) readBillingConfig(doc billingDocument) (*club.BillingConfig, error) {
+	config := &club.BillingConfig{
+		Strategy: doc.Strategy,
+		Block:    time.Duration(doc.BlockMinutes) * time.Minute,
+		Grace:    time.Duration(doc.GraceMinutes) * time.Minute,
+	}
+
+	if doc.GraceMinutes < 0 {
+		raw := strconv.Itoa(doc.GraceMinutes)
+		if err := dp.InvalidParse(newParseError(0, raw, 1, "billing.grace_minutes", ReasonInvalidInt, ErrParseInt)); err != nil {
+			return nil, err
+		}
+		config.Grace = 0
+	}
+
+	if _, err := table.NewBillingStrategy(config.Strategy, config.Block, config.Grace); err != nil {
+		if err := dp.InvalidParse(newParseError(0, doc.Strategy, 1, "billing.strategy", ReasonUnknownBilling, err)); err != nil {
+			return nil, err
+		}
+		config.Strategy = ""
+	}
+
+	return config, nil
+}
+
+func (dp *DocumentParser) readTariffs(docs []tariffDocument) ([]club.TariffBand, error) {
+	var bands []club.TariffBand
+
+	for i, doc := range docs {
+		raw := fmt.Sprintf("%s %s %s %d", doc.Name, doc.From, doc.To, doc.Price)
+
+		perr := validateTariff(i+1, raw, doc)
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		from, _ := time.Parse(club.TimeFormat, doc.From)
+		to, _ := time.Parse(club.TimeFormat, doc.To)
+		bands = append(bands, club.TariffBand{Name: doc.Name, TimeWindow: club.TimeWindow{From: from, To: to}, Price: doc.Price})
+	}
+
+	return bands, nil
+}
+
+// validateTariff checks a tariff band; Line is its position in the tariffs
+// array.
+func validateTariff(line int, raw string, doc tariffDocument) *ParseError {
+	if !validName(doc.Name) {
+		return newParseError(line, raw, 1, "tariffs.name", ReasonInvalidLabel, nil)
+	}
+
+	if _, err := time.Parse(club.TimeFormat, doc.From); err != nil {
+		return newParseError(line, raw, 2, "tariffs.from", ReasonInvalidTime, err)
+	}
+
+	if _, err := time.Parse(club.TimeFormat, doc.To); err != nil {
+		return newParseError(line, raw, 3, "tariffs.to", ReasonInvalidTime, err)
+	}
+
+	if _, err := parsePositiveInt(strconv.Itoa(doc.Price)); err != nil {
+		return newParseError(line, raw, 4, "tariffs.price", ReasonInvalidInt, err)
+	}
+
+	return nil
+}
+
+// readCategories checks the table categories; Line is the position of the
+// category in the categories array. A table may belong to one category only.
+func (dp *DocumentParser) readCategories(docs []categoryDocument, maxTables int) ([]club.TableCategory, error) {
+	var categories []club.TableCategory
+	assigned := make(map[int]bool)
+
+	for i, doc := range docs {
+		raw := fmt.Sprintf("%s %d %v", doc.Name, doc.Price, doc.Tables)
+
+		perr := validateCategory(i+1, raw, doc, maxTables, assigned)
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, tableID := range doc.Tables {
+			assigned[tableID] = true
+		}
+		categories = append(categories, club.TableCategory{Name: doc.Name, Price: doc.Price, Tables: doc.Tables})
+	}
+
+	return categories, nil
+}
+
+func validateCategory(line int, raw string, doc categoryDocument, maxTables int, assigned map[int]bool) *ParseError {
+	if !validName(doc.Name) || doc.Name == club.StandardCategory {
+		return newParseError(line, raw, 1, "categories.name", ReasonInvalidLabel, nil)
+	}
+
+	if _, err := parsePositiveInt(strconv.Itoa(doc.Price)); err != nil {
+		return newParseError(line, raw, 2, "categories.price", ReasonInvalidInt, err)
+	}
+
+	for _, tableID := range doc.Tables {
+		if tableID <= 0 || maxTables != 0 && tableID > maxTables || assigned[tableID] {
+			return newParseError(line, raw, 3, "categories.tables", ReasonTableRange, nil)
+		}
+	}
+
+	return nil
+}
+
+// readDiscounts checks the discounts of the club; Line is the position of
+// the entry in its array. A club without discounts gets nil.
+func (dp *DocumentParser) readDiscounts(doc discountsDocument) (*club.Discounts, error) {
+	discounts := &club.Discounts{}
+
+	for i, m := range doc.Memberships {
+		raw := fmt.Sprintf("%s %d %v", m.Name, m.Percent, m.Clients)
+
+		var perr *ParseError
+		switch {
+		case !validName(m.Name):
+			perr = newParseError(i+1, raw, 1, "discounts.memberships.name", ReasonInvalidLabel, nil)
+		case !validPercent(m.Percent):
+			perr = newParseError(i+1, raw, 2, "discounts.memberships.percent", ReasonInvalidPercent, nil)
+		}
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		discounts.Memberships = append(discounts.Memberships, club.Membership{Name: m.Name, Percent: m.Percent, Clients: m.Clients})
+	}
+
+	for i, h := range doc.HappyHours {
+		raw := fmt.Sprintf("%s %s %s %d", h.Name, h.From, h.To, h.Percent)
+
+		from, fromErr := time.Parse(club.TimeFormat, h.From)
+		to, toErr := time.Parse(club.TimeFormat, h.To)
+
+		var perr *ParseError
+		switch {
+		case !validName(h.Name):
+			perr = newParseError(i+1, raw, 1, "discounts.happy_hours.name", ReasonInvalidLabel, nil)
+		case fromErr != nil:
+			perr = newParseError(i+1, raw, 2, "discounts.happy_hours.from", ReasonInvalidTime, fromErr)
+		case toErr != nil:
+			perr = newParseError(i+1, raw, 3, "discounts.happy_hours.to", ReasonInvalidTime, toErr)
+		case !validPercent(h.Percent):
+			perr = newParseError(i+1, raw, 4, "discounts.happy_hours.percent", ReasonInvalidPercent, nil)
+		}
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		discounts.HappyHours = append(discounts.HappyHours, club.HappyHour{Name: h.Name, TimeWindow: club.TimeWindow{From: from, To: to}, Percent: h.Percent})
+	}
+
+	for i, b := range doc.Bundles {
+		raw := fmt.Sprintf("%s %d %d", b.Name, b.Hours, b.Paid)
+
+		var perr *ParseError
+		switch {
+		case !validName(b.Name):
+			perr = newParseError(i+1, raw, 1, "discounts.bundles.name", ReasonInvalidLabel, nil)
+		case b.Paid <= 0 || b.Hours <= b.Paid:
+			perr = newParseError(i+1, raw, 2, "discounts.bundles.hours", ReasonInvalidInt, ErrParseInt)
+		}
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		discounts.Bundles = append(discounts.Bundles, club.Bundle{Name: b.Name, Hours: b.Hours, Paid: b.Paid})
+	}
+
+	for i, p := range doc.PromoCodes {
+		raw := fmt.Sprintf("%s %d", p.Code, p.Percent)
+
+		var perr *ParseError
+		switch {
+		case !validName(p.Code):
+			perr = newParseError(i+1, raw, 1, "discounts.promo_codes.code", ReasonInvalidLabel, nil)
+		case !validPercent(p.Percent):
+			perr = newParseError(i+1, raw, 2, "discounts.promo_codes.percent", ReasonInvalidPercent, nil)
+		}
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		discounts.PromoCodes = append(discounts.PromoCodes, club.PromoCode{Code: p.Code, Percent: p.Percent})
+	}
+
+	if len(discounts.Memberships) == 0 && len(discounts.HappyHours) == 0 && len(discounts.Bundles) == 0 && len(discounts.PromoCodes) == 0 {
+		return nil, nil
+	}
+	return discounts, nil
+}
+
+// readPackages checks the time packages of the club; Line is the position
+// of the package in the packages array. Package names must be unique.
+func (dp *DocumentParser) readPackages(docs []packageDocument) ([]club.TimePackage, error) {
+	var packages []club.TimePackage
+
+	for i, doc := range docs {
+		raw := fmt.Sprintf("%s %s %s %d", doc.Name, doc.From, doc.To, doc.Price)
+
+		from, fromErr := time.Parse(club.TimeFormat, doc.From)
+		to, toErr := time.Parse(club.TimeFormat, doc.To)
+		_, duplicate := club.FindPackage(packages, doc.Name)
+
+		var perr *ParseError
+		switch {
+		case !validName(doc.Name) || duplicate:
+			perr = newParseError(i+1, raw, 1, "packages.name", ReasonInvalidLabel, nil)
+		case fromErr != nil:
+			perr = newParseError(i+1, raw, 2, "packages.from", ReasonInvalidTime, fromErr)
+		case toErr != nil:
+			perr = newParseError(i+1, raw, 3, "packages.to", ReasonInvalidTime, toErr)
+		case to.Equal(from):
+			perr = newParseError(i+1, raw, 3, "packages.to", ReasonInvalidHours, nil)
+		case doc.Price <= 0:
+			perr = newParseError(i+1, raw, 4, "packages.price", ReasonInvalidInt, ErrParseInt)
+		}
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		packages = append(packages, club.TimePackage{Name: doc.Name, TimeWindow: club.TimeWindow{From: from, To: to}, Price: doc.Price})
+	}
+
+	return packages, nil
+}
+
+// namePattern matches the names of tariffs, categories, discounts and
+// packages. They are single words, as events and reports refer to them.
+var namePattern = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+func validName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+func validPercent(percent int) bool {
+	return percent > 0 && percent <= 100
+}
+
+func (dp *DocumentParser) ReadManagerEvents(activeClub *club.Club) ([]*club.Manager, error) {
+	if err := dp.decode(); err != nil {
+		return nil, err
+	}
+
+	var (
+		managers []*club.Manager
+	)
+
+	for i, event := range dp.doc.Events {
+		parts := event.fields()
+
+		raw := strings.Join(parts, " ")
+		manager, perr := parseEvent(i+1, raw, parts, activeClub)
+		if perr == nil {
+			perr = dp.checkDating(i+1, raw, manager)
+		}
+		if perr != nil {
+			if err := dp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		managers = append(managers, manager)
+	}
+
+	if len(dp.errs) != 0 {
+		return nil, dp.errs
+	}
+
+	sortManagers(managers)
+	return managers, nil
+}
+
+// DecodeEvent reads a single JSON event in the layout of the events array
+// of a document and validates it against the club. An event without time
+// happens at now, dated only when the log it joins is dated.
+func DecodeEvent(r io.Reader, activeClub *club.Club, now time.Time, dated bool) (*club.Manager, error) {
+	var event eventDocument
+	if err := json.NewDecoder(r).Decode(&event); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReadData, err)
+	}
+
+	if event.Time == "" {
+		event.Time = now.Format(club.TimeFormat)
+		if dated {
+			event.Time = now.Format(club.DateTimeFormat)
+		}
+	}
+
+	parts := event.fields()
+	manager, perr := parseEvent(1, strings.Join(parts, " "), parts, activeClub)
+	if perr != nil {
+		return nil, perr
+	}
+	return manager, nil
+}
+
+func NewDocumentParser(r io.Reader, format Format) *DocumentParser {
+	return &DocumentParser{
+		reader: r,
+		format: format,
+	}
+}
@@ -0,0 +1,128 @@
+package myparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDocumentParserJSON(t *testing.T) {
+	parser := NewDocumentParser(strings.NewReader(`{
+  "club": {"tables": 2, "open": "09:00", "close": "19:00", "price": 10},
+  "events": [
+    {"time": "09:54", "id": 2, "client": "anna", "table": 1},
+    {"time": "09:41", "id": 1, "client": "anna"}
+  ]
+}`), FormatJSON)
+
+	clubInfo, err := parser.ReadClubInfo()
+	if err != nil {
+		t.Fatalf("ReadClubInfo returned error: %v", err)
+	}
+
+	if clubInfo.MaxTables != 2 || clubInfo.Price != 10 {
+		t.Errorf("Unexpected club %+v", clubInfo)
+	}
+
+	managers, err := parser.ReadManagerEvents(clubInfo)
+	if err != nil {
+		t.Fatalf("ReadManagerEvents returned error: %v", err)
+	}
+
+	expectedFirstTime, _ := time.Parse("15:04", "09:41")
+	if len(managers) != 2 || !managers[0].Time.Equal(expectedFirstTime) || managers[1].TableID != 1 {
+		t.Errorf("Unexpected events %+v", managers)
+	}
+}
+
+func TestDocumentParserYAMLInvalidEvent(t *testing.T) {
+	parser := NewDocumentParser(strings.NewReader(`club:
+  tables: 2
+  open: "09:00"
+  close: "19:00"
+  price: 10
+events:
+  - {time: "09:41", id: 1, client: anna}
+  - {time: "09:54", id: 2, client: anna, table: 3}
+`), FormatYAML)
+
+	clubInfo, err := parser.ReadClubInfo()
+	if err != nil {
+		t.Fatalf("ReadClubInfo returned error: %v", err)
+	}
+
+	_, err = parser.ReadManagerEvents(clubInfo)
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+
+	if parseErr.Line != 2 || parseErr.Reason != ReasonTableRange || parseErr.Raw != "09:54 2 anna 3" {
+		t.Errorf("Unexpected parse error %+v", parseErr)
+	}
+}
+
+func TestDocumentParserDiscounts(t *testing.T) {
+	parser := NewDocumentParser(strings.NewReader(`club:
+  tables: 2
+  open: "09:00"
+  close: "19:00"
+  price: 10
+  discounts:
+    memberships:
+      - {name: gold, percent: 20, clients: [anna]}
+    bundles:
+      - {name: three-for-two, hours: 3, paid: 2}
+    promo_codes:
+      - {code: welcome, percent: 130}
+      - {code: friends, percent: 10}
+events:
+  - {time: "09:41", id: 1, client: boris, promo: friends}
+`), FormatYAML)
+	parser.CollectAll()
+
+	clubInfo, _ := parser.ReadClubInfo()
+	_, err := parser.ReadManagerEvents(clubInfo)
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "discounts.promo_codes.percent" || errs[0].Reason != ReasonInvalidPercent {
+		t.Fatalf("Expected a single promo code percent error, got %v", err)
+	}
+
+	if clubInfo.Discounts.MembershipPercent("anna") != 20 || len(clubInfo.Discounts.Bundles) != 1 {
+		t.Errorf("Unexpected discounts %+v", clubInfo.Discounts)
+	}
+}
+
+func TestDocumentParserNames(t *testing.T) {
+	parser := NewDocumentParser(strings.NewReader(`club:
+  tables: 2
+  open: "09:00"
+  close: "19:00"
+  price: 10
+  tariffs:
+    - {name: Evening_Peak, from: "18:00", to: "22:00", price: 20}
+    - {name: "happy hour", from: "12:00", to: "14:00", price: 5}
+  packages:
+    - {name: "ночь", from: "22:00", to: "08:00", price: 50}
+events:
+  - {time: "09:41", id: 1, client: anna}
+`), FormatYAML)
+	parser.CollectAll()
+
+	clubInfo, _ := parser.ReadClubInfo()
+	_, err := parser.ReadManagerEvents(clubInfo)
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Expected two name errors, got %v", err)
+	}
+	if errs[0].Field != "tariffs.name" || errs[0].Reason != ReasonInvalidLabel {
+		t.Errorf("Unexpected tariff error %+v", errs[0])
+	}
+	if errs[1].Field != "packages.name" || errs[1].Reason != ReasonInvalidLabel {
+		t.Errorf("Unexpected package error %+v", errs[1])
+	}
+}
@@ -0,0 +1,69 @@
+package myparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ReasonCode string
+
+const (
+	ReasonEmptyLine      ReasonCode = "EmptyLine"
+	ReasonInvalidInt     ReasonCode = "InvalidInt"
+	ReasonInvalidTime    ReasonCode = "InvalidTime"
+	ReasonInvalidHours   ReasonCode = "InvalidWorkingHours"
+	ReasonFieldCount     ReasonCode = "WrongFieldCount"
+	ReasonInvalidName    ReasonCode = "InvalidUsername"
+	ReasonInvalidLabel   ReasonCode = "InvalidName"
+	ReasonMissingTable   ReasonCode = "MissingTable"
+	ReasonMissingAmount  ReasonCode = "MissingAmount"
+	ReasonInvalidPercent ReasonCode = "InvalidPercent"
+	ReasonMissingPackage ReasonCode = "MissingPackage"
+	ReasonUnexpectedArg  ReasonCode = "UnexpectedTable"
+	ReasonTableRange     ReasonCode = "TableOutOfRange"
+	ReasonUnknownPolicy  ReasonCode = "UnknownQueuePolicy"
+	ReasonUnknownBilling ReasonCode = "UnknownBillingStrategy"
+	ReasonMixedDates     ReasonCode = "MixedDatedAndUndatedEvents"
+)
+
+// ParseError describes a single invalid line of the input. Column is the
+// 1-based index of the offending field, or 0 when the whole line is wrong.
+type ParseError struct {
+	Line   int
+	Column int
+	Field  string
+	Raw    string
+	Reason ReasonCode
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("line %d: %s: %q", e.Line, e.Reason, e.Raw)
+	}
+	return fmt.Sprintf("line %d, field %d (%s): %s: %q", e.Line, e.Column, e.Field, e.Reason, e.Raw)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is the report returned when the parser collects all problems
+// of the input instead of stopping at the first one.
+type ParseErrors []*ParseError
+
+func (pe ParseErrors) Error() string {
+	lines := make([]string, 0, len(pe))
+	for _, e := range pe {
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (pe ParseErrors) Unwrap() []error {
+	errs := make([]error, 0, len(pe))
+	for _, e := range pe {
+		errs = append(errs, e)
+	}
+	return errs
+}
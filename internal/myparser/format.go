@@ -0,0 +1,58 @@
+package myparser
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("UnknownFormat")
+
+type Format string
+
+const (
+	FormatAuto Format = "auto"
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatAuto:
+		return FormatAuto, nil
+	case FormatText, "txt":
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// FormatFromPath detects the input format by the file extension and falls
+// back to the text layout.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".yaml", ".yml":
+		return FormatYAML
+	}
+	return FormatText
+}
+
+type CollectingParser interface {
+	Parser
+	CollectAll()
+}
+
+func NewParser(r io.Reader, format Format) CollectingParser {
+	switch format {
+	case FormatJSON, FormatYAML:
+		return NewDocumentParser(r, format)
+	}
+	return NewFileParser(r)
+}
@@ -3,8 +3,7 @@ package myparser
 import (
 	"bufio"
 	"errors"
-	"fmt"
-	"os"
+	"io"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,14 +22,66 @@ type Parser interface {
 	ReadClubInfo() (*club.Club, error)
 	ReadManagerEvents(activeClub *club.Club) ([]*club.Manager, error)
 	ParseInt(data string) (int, error)
-	InvalidParse(line []string)
+	InvalidParse(err *ParseError) error
+}
+
+// collector implements the error handling shared by the parsers: either
+// stop at the first invalid entry or collect all of them into ParseErrors.
+type collector struct {
+	collectAll bool
+	errs       ParseErrors
+	first      *club.Manager
+}
+
+// CollectAll switches the parser to report every invalid line at once.
+// Problems found by ReadClubInfo are then returned together with the event
+// problems by ReadManagerEvents as ParseErrors.
+func (c *collector) CollectAll() {
+	c.collectAll = true
+}
+
+func (c *collector) ParseInt(data string) (int, error) {
+	return parsePositiveInt(data)
+}
+
+func (c *collector) InvalidParse(err *ParseError) error {
+	if c.collectAll {
+		c.errs = append(c.errs, err)
+		return nil
+	}
+	return err
+}
+
+// checkDating rejects an event dated when the events before it are not, or
+// the other way round: a log either has dates on every line or on none.
+func (c *collector) checkDating(line int, raw string, manager *club.Manager) *ParseError {
+	if c.first == nil {
+		c.first = manager
+		return nil
+	}
+
+	if (c.first.Time.Year() == 0) != (manager.Time.Year() == 0) {
+		return newParseError(line, raw, 1, "time", ReasonMixedDates, nil)
+	}
+	return nil
+}
+
+// endOfData reports the input ending before the club settings did, together
+// with the problems collected so far.
+func (c *collector) endOfData() error {
+	if len(c.errs) == 0 {
+		return ErrReadData
+	}
+	return errors.Join(c.errs, ErrReadData)
 }
 
 type FileParser struct {
+	collector
 	scanner *bufio.Scanner
+	line    int
 }
 
-func (fp *FileParser) ParseInt(data string) (int, error) {
+func parsePositiveInt(data string) (int, error) {
 	v, err := strconv.Atoi(data)
 	if err != nil || v <= 0 {
 		return 0, ErrParseInt
@@ -38,130 +89,334 @@ func (fp *FileParser) ParseInt(data string) (int, error) {
 	return v, nil
 }
 
-func (fp *FileParser) InvalidParse(line []string) {
-	fmt.Println(line)
-	os.Exit(1)
+func (fp *FileParser) scan() (string, bool) {
+	if !fp.scanner.Scan() {
+		return "", false
+	}
+	fp.line++
+	return fp.scanner.Text(), true
+}
+
+func (fp *FileParser) newError(raw string, column int, field string, reason ReasonCode, err error) *ParseError {
+	return newParseError(fp.line, raw, column, field, reason, err)
+}
+
+func newParseError(line int, raw string, column int, field string, reason ReasonCode, err error) *ParseError {
+	return &ParseError{
+		Line:   line,
+		Column: column,
+		Field:  field,
+		Raw:    raw,
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+func (fp *FileParser) readPositiveInt(field string) (int, error) {
+	data, ok := fp.scan()
+	if !ok {
+		return 0, fp.endOfData()
+	}
+
+	if len(data) == 0 {
+		return 0, fp.InvalidParse(fp.newError(data, 0, field, ReasonEmptyLine, nil))
+	}
+
+	v, err := fp.ParseInt(data)
+	if err != nil {
+		return 0, fp.InvalidParse(fp.newError(data, 1, field, ReasonInvalidInt, err))
+	}
+	return v, nil
 }
 
 func (fp *FileParser) ReadClubInfo() (*club.Club, error) {
-	if !fp.scanner.Scan() {
-		return nil, ErrReadData
+	maxTables, err := fp.readPositiveInt("tables")
+	if err != nil {
+		return nil, err
 	}
 
-	maxTablesData := fp.scanner.Text()
-	if len(maxTablesData) == 0 {
-		fp.InvalidParse([]string{maxTablesData})
+	workingTime, err := fp.readWorkingTime()
+	if err != nil {
+		return nil, err
 	}
 
-	maxTables, err := fp.ParseInt(maxTablesData)
+	price, err := fp.readPositiveInt("price")
 	if err != nil {
-		fp.InvalidParse([]string{maxTablesData})
+		return nil, err
 	}
 
-	if !fp.scanner.Scan() {
-		return nil, ErrReadData
+	return club.NewClub(workingTime, price, maxTables), nil
+}
+
+func (fp *FileParser) readWorkingTime() (*club.WorkingTime, error) {
+	workingTimeData, ok := fp.scan()
+	if !ok {
+		return nil, fp.endOfData()
 	}
 
-	workingTimeData := fp.scanner.Text()
 	if len(workingTimeData) == 0 {
-		fp.InvalidParse([]string{workingTimeData})
+		return club.NewWorkingTime(time.Time{}, time.Time{}),
+			fp.InvalidParse(fp.newError(workingTimeData, 0, "hours", ReasonEmptyLine, nil))
 	}
 
 	times := strings.Split(workingTimeData, " ")
 	if len(times) != 2 {
-		fp.InvalidParse(times)
+		return club.NewWorkingTime(time.Time{}, time.Time{}),
+			fp.InvalidParse(fp.newError(workingTimeData, 0, "hours", ReasonFieldCount, nil))
 	}
 
-	startTime, err := time.Parse(club.TimeFormat, times[0])
+	workingTime, perr := parseWorkingTime(fp.line, workingTimeData, times[0], times[1])
+	if perr != nil {
+		return workingTime, fp.InvalidParse(perr)
+	}
+
+	return workingTime, nil
+}
+
+func parseWorkingTime(line int, raw, open, close string) (*club.WorkingTime, *ParseError) {
+	startTime, err := time.Parse(club.TimeFormat, open)
 	if err != nil {
-		fp.InvalidParse(times)
+		return club.NewWorkingTime(time.Time{}, time.Time{}),
+			newParseError(line, raw, 1, "open", ReasonInvalidTime, err)
 	}
 
-	endTime, err := time.Parse(club.TimeFormat, times[1])
+	endTime, err := time.Parse(club.TimeFormat, close)
 	if err != nil {
-		fp.InvalidParse(times)
+		return club.NewWorkingTime(startTime, startTime),
+			newParseError(line, raw, 2, "close", ReasonInvalidTime, err)
 	}
 
-	if endTime.Before(startTime) {
-		fp.InvalidParse(times)
+	if endTime.Equal(startTime) {
+		return club.NewWorkingTime(startTime, endTime),
+			newParseError(line, raw, 2, "close", ReasonInvalidHours, nil)
 	}
-	workingTime := club.NewWorkingTime(startTime, endTime)
 
-	if !fp.scanner.Scan() {
-		return nil, ErrReadData
+	return club.NewWorkingTime(startTime, endTime), nil
+}
+
+func (fp *FileParser) ReadManagerEvents(activeClub *club.Club) ([]*club.Manager, error) {
+	var (
+		managers []*club.Manager
+	)
+
+	for {
+		line, ok := fp.scan()
+		if !ok {
+			break
+		}
+
+		manager, perr := parseEvent(fp.line, line, strings.Fields(line), activeClub)
+		if perr == nil {
+			perr = fp.checkDating(fp.line, line, manager)
+		}
+		if perr != nil {
+			if err := fp.InvalidParse(perr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		managers = append(managers, manager)
+	}
+
+	if err := fp.scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	priceData := fp.scanner.Text()
-	if len(priceData) == 0 {
-		fp.InvalidParse([]string{priceData})
+	if len(fp.errs) != 0 {
+		return nil, fp.errs
 	}
 
-	price, err := fp.ParseInt(priceData)
+	sortManagers(managers)
+	return managers, nil
+}
+
+func sortManagers(managers []*club.Manager) {
+	sort.Slice(managers, func(i, j int) bool {
+		return managers[i].Time.Before(managers[j].Time)
+	})
+}
+
+// parseEventTime reads "15:04" or "2006-01-02 15:04". Times without a date
+// after midnight of an overnight club are moved to the next day.
+func parseEventTime(data string, activeClub *club.Club) (time.Time, error) {
+	if eventTime, err := time.Parse(club.DateTimeFormat, data); err == nil {
+		return eventTime, nil
+	}
+
+	eventTime, err := time.Parse(club.TimeFormat, data)
 	if err != nil {
-		fp.InvalidParse([]string{priceData})
+		return time.Time{}, err
 	}
 
-	return club.NewClub(workingTime, price, maxTables), nil
+	if activeClub.WorkingTime != nil {
+		eventTime = activeClub.WorkingTime.Normalize(eventTime)
+	}
+	return eventTime, nil
 }
 
-func (fp *FileParser) ReadManagerEvents(activeClub *club.Club) ([]*club.Manager, error) {
-	var (
-		managers []*club.Manager
-	)
+// parseEvent validates the fields of a single event: time with an optional
+// date, id, client and an optional table number. A booking takes a table
+// number or a category, the start and the length in minutes instead, a
+// top-up takes the amount, a package purchase the name of the package and
+// an arrival an optional promo code, if the club has any.
+func parseEvent(line int, raw string, parts []string, activeClub *club.Club) (*club.Manager, *ParseError) {
+	if len(parts) > 1 {
+		if _, err := time.Parse(club.DateFormat, parts[0]); err == nil {
+			parts = append([]string{parts[0] + " " + parts[1]}, parts[2:]...)
+		}
+	}
+
+	booking := len(parts) > 1 && parts[1] == strconv.Itoa(club.IncomingClientBooked)
+	if len(parts) < 3 || (len(parts) > 4 && !booking) || (booking && len(parts) != 6) {
+		return nil, newParseError(line, raw, 0, "event", ReasonFieldCount, nil)
+	}
 
-	for fp.scanner.Scan() {
-		line := fp.scanner.Text()
+	eventTime, err := parseEventTime(parts[0], activeClub)
+	if err != nil {
+		return nil, newParseError(line, raw, 1, "time", ReasonInvalidTime, err)
+	}
 
-		parts := strings.Fields(line)
-		if len(parts) < 3 || len(parts) > 4 {
-			fp.InvalidParse([]string{line})
+	eventType, err := parsePositiveInt(parts[1])
+	if err != nil {
+		return nil, newParseError(line, raw, 2, "id", ReasonInvalidInt, err)
+	}
+
+	if eventType == club.IncomingClientTookTheTable && len(parts) < 4 {
+		return nil, newParseError(line, raw, 4, "table", ReasonMissingTable, nil)
+	}
+
+	if eventType == club.IncomingClientToppedUp && len(parts) < 4 {
+		return nil, newParseError(line, raw, 4, "amount", ReasonMissingAmount, nil)
+	}
+
+	if eventType == club.IncomingClientBoughtPackage && len(parts) < 4 {
+		return nil, newParseError(line, raw, 4, "package", ReasonMissingPackage, nil)
+	}
+
+	if ok, _ := client.ValidateUsername(parts[2]); !ok {
+		return nil, newParseError(line, raw, 3, "client", ReasonInvalidName, client.ErrValidationName)
+	}
+
+	clientName := parts[2]
+
+	if booking {
+		reservation, perr := parseReservation(line, raw, parts, eventTime, activeClub)
+		if perr != nil {
+			return nil, perr
 		}
 
-		eventTime, err := time.Parse(club.TimeFormat, parts[0])
+		manager := club.NewManager(eventTime, eventType, clientName, 0)
+		manager.Reservation = reservation
+		return manager, nil
+	}
+
+	if eventType == club.IncomingClientToppedUp {
+		amount, err := parsePositiveInt(parts[3])
 		if err != nil {
-			fp.InvalidParse([]string{line})
+			return nil, newParseError(line, raw, 4, "amount", ReasonInvalidInt, err)
 		}
 
-		eventType, err := fp.ParseInt(parts[1])
-		if err != nil || eventType == 2 && len(parts) < 4 {
-			fp.InvalidParse([]string{line})
+		manager := club.NewManager(eventTime, eventType, clientName, 0)
+		manager.Amount = amount
+		return manager, nil
+	}
+
+	if eventType == club.IncomingClientBoughtPackage {
+		if !validName(parts[3]) {
+			return nil, newParseError(line, raw, 4, "package", ReasonInvalidLabel, nil)
 		}
 
-		if ok, _ := client.ValidateUsername(parts[2]); !ok {
-			fp.InvalidParse([]string{line})
+		manager := club.NewManager(eventTime, eventType, clientName, 0)
+		manager.Package = parts[3]
+		return manager, nil
+	}
+
+	if eventType == club.IncomingClientCome && len(parts) > 3 && promoField(parts[3], activeClub) {
+		if !validName(parts[3]) {
+			return nil, newParseError(line, raw, 4, "promo", ReasonInvalidLabel, nil)
 		}
 
-		clientName := parts[2]
+		manager := club.NewManager(eventTime, eventType, clientName, 0)
+		manager.PromoCode = parts[3]
+		return manager, nil
+	}
 
-		var tableID int
-		if len(parts) > 3 {
-			if eventType != 2 {
-				fp.InvalidParse([]string{line})
-			}
+	var tableID int
+	if len(parts) > 3 {
+		if eventType != 2 {
+			return nil, newParseError(line, raw, 4, "table", ReasonUnexpectedArg, nil)
+		}
 
-			tableID, err = fp.ParseInt(parts[3])
-			if err != nil || tableID > activeClub.MaxTables {
-				fp.InvalidParse([]string{line})
-			}
+		tableID, err = parsePositiveInt(parts[3])
+		if err != nil {
+			return nil, newParseError(line, raw, 4, "table", ReasonInvalidInt, err)
 		}
 
-		manager := club.NewManager(eventTime, eventType, clientName, tableID)
-		managers = append(managers, manager)
+		if activeClub.MaxTables != 0 && tableID > activeClub.MaxTables {
+			return nil, newParseError(line, raw, 4, "table", ReasonTableRange, nil)
+		}
 	}
 
-	if err := fp.scanner.Err(); err != nil {
-		return nil, err
+	return club.NewManager(eventTime, eventType, clientName, tableID), nil
+}
+
+// promoField reports whether the last field of an arrival is meant as a
+// promo code: the club has promo codes and the field is not a number, which
+// would be a table given to the wrong event.
+func promoField(data string, activeClub *club.Club) bool {
+	if activeClub.Discounts == nil || len(activeClub.Discounts.PromoCodes) == 0 {
+		return false
 	}
 
-	sort.Slice(managers, func(i, j int) bool {
-		return managers[i].Time.Before(managers[j].Time)
-	})
+	_, err := strconv.Atoi(data)
+	return err != nil
+}
 
-	return managers, nil
+// parseReservation reads the table or the category, the start and the
+// length of a booking. The start is a clock time of the working day the
+// booking is made on.
+func parseReservation(line int, raw string, parts []string, eventTime time.Time, activeClub *club.Club) (*club.Reservation, *ParseError) {
+	reservation := &club.Reservation{}
+
+	if _, err := strconv.Atoi(parts[3]); err != nil {
+		if !validName(parts[3]) {
+			return nil, newParseError(line, raw, 4, "category", ReasonInvalidLabel, nil)
+		}
+		reservation.Category = parts[3]
+	} else if tableID, err := parsePositiveInt(parts[3]); err != nil {
+		return nil, newParseError(line, raw, 4, "table", ReasonInvalidInt, err)
+	} else if activeClub.MaxTables != 0 && tableID > activeClub.MaxTables {
+		return nil, newParseError(line, raw, 4, "table", ReasonTableRange, nil)
+	} else {
+		reservation.TableID = tableID
+	}
+
+	start, err := time.Parse(club.TimeFormat, parts[4])
+	if err != nil {
+		return nil, newParseError(line, raw, 5, "start", ReasonInvalidTime, err)
+	}
+
+	if activeClub.WorkingTime != nil {
+		start = activeClub.WorkingTime.Normalize(start)
+		if eventTime.Year() != 0 {
+			day := activeClub.WorkingTime.DayOf(eventTime)
+			start = day.Add(start.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)))
+		}
+	}
+	reservation.Start = start
+
+	minutes, err := parsePositiveInt(parts[5])
+	if err != nil {
+		return nil, newParseError(line, raw, 6, "minutes", ReasonInvalidInt, err)
+	}
+	reservation.Duration = time.Duration(minutes) * time.Minute
+
+	return reservation, nil
 }
 
-func NewFileParser(file *os.File) *FileParser {
+func NewFileParser(r io.Reader) *FileParser {
 	return &FileParser{
-		scanner: bufio.NewScanner(file),
+		scanner: bufio.NewScanner(r),
 	}
 }
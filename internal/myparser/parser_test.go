@@ -1,7 +1,9 @@
 package myparser
 
 import (
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -144,3 +146,298 @@ func TestReadClubInfo(t *testing.T) {
 		t.Errorf("Expected Price to be %d, got %d", expectedPrice, clubInfo.Price)
 	}
 }
+
+func TestReadManagerEventsInvalidLine(t *testing.T) {
+	file, err := os.CreateTemp("", "test_data")
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(`10:00 1 anna
+10:01 2 anna
+`)
+	if err != nil {
+		t.Fatalf("Error writing test data to file: %v", err)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatalf("Error resetting file pointer: %v", err)
+	}
+
+	parser := NewFileParser(file)
+
+	_, err = parser.ReadManagerEvents(&club.Club{MaxTables: 4})
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected *ParseError, got %v", err)
+	}
+
+	if parseErr.Line != 2 || parseErr.Column != 4 || parseErr.Reason != ReasonMissingTable || parseErr.Raw != "10:01 2 anna" {
+		t.Errorf("Unexpected parse error %+v", parseErr)
+	}
+}
+
+func TestCollectAllErrors(t *testing.T) {
+	file, err := os.CreateTemp("", "test_data")
+	if err != nil {
+		t.Fatalf("Error creating temporary file: %v", err)
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(`3
+10:00 23:00
+zero
+10:00 1 anna
+10:01 1 %$#@
+10:02 2 anna 7
+10:03 4 anna
+`)
+	if err != nil {
+		t.Fatalf("Error writing test data to file: %v", err)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatalf("Error resetting file pointer: %v", err)
+	}
+
+	parser := NewFileParser(file)
+	parser.CollectAll()
+
+	clubInfo, err := parser.ReadClubInfo()
+	if err != nil {
+		t.Fatalf("ReadClubInfo returned error: %v", err)
+	}
+
+	_, err = parser.ReadManagerEvents(clubInfo)
+
+	var report ParseErrors
+	if !errors.As(err, &report) {
+		t.Fatalf("Expected ParseErrors, got %v", err)
+	}
+
+	expectedLines := []int{3, 5, 6}
+	if len(report) != len(expectedLines) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expectedLines), len(report), report)
+	}
+
+	for i, line := range expectedLines {
+		if report[i].Line != line {
+			t.Errorf("Expected error %d on line %d, got line %d", i, line, report[i].Line)
+		}
+	}
+
+	if report[2].Reason != ReasonTableRange {
+		t.Errorf("Expected reason %s, got %s", ReasonTableRange, report[2].Reason)
+	}
+}
+
+func TestCollectAllErrorsTruncatedClub(t *testing.T) {
+	parser := NewFileParser(strings.NewReader("two\n09:00 19:xx\n"))
+	parser.CollectAll()
+
+	_, err := parser.ReadClubInfo()
+	if !errors.Is(err, ErrReadData) {
+		t.Fatalf("Expected %v, got %v", ErrReadData, err)
+	}
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "tables" || errs[1].Field != "close" {
+		t.Errorf("Expected the tables and close errors kept, got %v", err)
+	}
+
+	parser = NewFileParser(strings.NewReader("3\n"))
+	parser.CollectAll()
+
+	if _, err := parser.ReadClubInfo(); err != ErrReadData {
+		t.Errorf("Expected a bare %v without parse errors, got %v", ErrReadData, err)
+	}
+}
+
+func TestNewFileParserFromReader(t *testing.T) {
+	parser := NewFileParser(strings.NewReader(`2
+09:00 19:00
+10
+09:41 1 anna
+`))
+
+	clubInfo, err := parser.ReadClubInfo()
+	if err != nil {
+		t.Fatalf("ReadClubInfo returned error: %v", err)
+	}
+
+	managers, err := parser.ReadManagerEvents(clubInfo)
+	if err != nil {
+		t.Fatalf("ReadManagerEvents returned error: %v", err)
+	}
+
+	if len(managers) != 1 || managers[0].Client.Username != "anna" {
+		t.Errorf("Expected a single event for anna, got %+v", managers)
+	}
+}
+
+func TestReadOvernightClub(t *testing.T) {
+	parser := NewFileParser(strings.NewReader(`2
+20:00 06:00
+10
+01:20 4 anna
+20:10 1 anna
+23:45 2 anna 1
+`))
+
+	clubInfo, err := parser.ReadClubInfo()
+	if err != nil {
+		t.Fatalf("ReadClubInfo returned error: %v", err)
+	}
+
+	if !clubInfo.WorkingTime.Overnight() {
+		t.Fatalf("Expected 20:00-06:00 to be an overnight club")
+	}
+
+	managers, err := parser.ReadManagerEvents(clubInfo)
+	if err != nil {
+		t.Fatalf("ReadManagerEvents returned error: %v", err)
+	}
+
+	if managers[0].ID != 1 || managers[2].ID != 4 {
+		t.Fatalf("Expected events after midnight to go last, got %+v", managers)
+	}
+
+	if d := managers[2].Time.Sub(managers[1].Time); d != 95*time.Minute {
+		t.Errorf("Expected 01:35 between 23:45 and 01:20, got %v", d)
+	}
+}
+
+func TestReadDatedEvents(t *testing.T) {
+	parser := NewFileParser(strings.NewReader(`2026-10-19 09:00 1 boris
+2026-10-18 09:41 2 anna 1
+`))
+
+	managers, err := parser.ReadManagerEvents(&club.Club{MaxTables: 2})
+	if err != nil {
+		t.Fatalf("ReadManagerEvents returned error: %v", err)
+	}
+
+	expectedFirstTime, _ := time.Parse(club.DateTimeFormat, "2026-10-18 09:41")
+	if !managers[0].Time.Equal(expectedFirstTime) || managers[0].TableID != 1 {
+		t.Errorf("Expected anna at table 1 on %v first, got %+v", expectedFirstTime, managers[0])
+	}
+
+	if managers[1].Client.Username != "boris" {
+		t.Errorf("Expected boris on the next day last, got %+v", managers[1])
+	}
+}
+
+func TestReadMixedDatedEvents(t *testing.T) {
+	workingTime := club.NewWorkingTime(clock(9, 0), clock(19, 0))
+
+	parser := NewFileParser(strings.NewReader("2026-10-18 09:41 1 anna\n09:50 1 boris\n2026-10-18 10:00 1 clara\n"))
+	parser.CollectAll()
+
+	_, err := parser.ReadManagerEvents(&club.Club{MaxTables: 2, WorkingTime: workingTime})
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Line != 2 || errs[0].Reason != ReasonMixedDates {
+		t.Fatalf("Expected a single mixed dates error on line 2, got %v", err)
+	}
+
+	doc := NewDocumentParser(strings.NewReader(`{"events": [
+		{"time": "09:41", "id": 1, "client": "anna"},
+		{"time": "2026-10-18 09:50", "id": 1, "client": "boris"}
+	]}`), FormatJSON)
+
+	_, err = doc.ReadManagerEvents(&club.Club{MaxTables: 2, WorkingTime: workingTime})
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 2 || perr.Reason != ReasonMixedDates {
+		t.Errorf("Expected a mixed dates error on the second event, got %v", err)
+	}
+}
+
+func TestReadBookingEvents(t *testing.T) {
+	parser := NewFileParser(strings.NewReader(`09:00 5 anna 2 10:30 90
+09:05 5 boris vip 11:00 60
+09:06 5 boris 3 11:00 60
+09:07 5 clara 0 11:00 60
+09:08 5 dima vip+ 11:00 60
+`))
+	parser.CollectAll()
+
+	workingTime := club.NewWorkingTime(clock(9, 0), clock(19, 0))
+	_, err := parser.ReadManagerEvents(&club.Club{MaxTables: 2, WorkingTime: workingTime})
+
+	var errs ParseErrors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %v", err)
+	}
+	if errs[0].Line != 3 || errs[0].Reason != ReasonTableRange {
+		t.Errorf("Expected a table range error on line 3, got %+v", errs[0])
+	}
+	if errs[1].Line != 4 || errs[1].Reason != ReasonInvalidInt {
+		t.Errorf("Expected an invalid table on line 4, got %+v", errs[1])
+	}
+	if errs[2].Line != 5 || errs[2].Field != "category" || errs[2].Reason != ReasonInvalidLabel {
+		t.Errorf("Expected an invalid category on line 5, got %+v", errs[2])
+	}
+
+	parser = NewFileParser(strings.NewReader("09:00 5 anna 2 10:30 90\n09:05 5 boris VIP_Room 11:00 60\n"))
+	managers, err := parser.ReadManagerEvents(&club.Club{MaxTables: 2, WorkingTime: workingTime})
+	if err != nil {
+		t.Fatalf("ReadManagerEvents returned error: %v", err)
+	}
+
+	anna := managers[0].Reservation
+	if anna == nil || anna.TableID != 2 || !anna.Start.Equal(clock(10, 30)) || anna.Duration != 90*time.Minute {
+		t.Errorf("Unexpected reservation %+v", anna)
+	}
+	if boris := managers[1].Reservation; boris == nil || boris.Category != "VIP_Room" || boris.TableID != 0 {
+		t.Errorf("Unexpected reservation %+v", boris)
+	}
+}
+
+func TestReadPromoCodes(t *testing.T) {
+	input := "09:00 1 anna welcome\n09:05 1 boris 5\n"
+	promo := &club.Discounts{PromoCodes: []club.PromoCode{{Code: "welcome", Percent: 10}}}
+
+	tests := []struct {
+		name      string
+		discounts *club.Discounts
+		errLines  []int
+	}{
+		{name: "club without promo codes", errLines: []int{1, 2}},
+		{name: "club with promo codes", discounts: promo, errLines: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewFileParser(strings.NewReader(input))
+			parser.CollectAll()
+
+			_, err := parser.ReadManagerEvents(&club.Club{MaxTables: 2, Discounts: tt.discounts})
+
+			var errs ParseErrors
+			if !errors.As(err, &errs) || len(errs) != len(tt.errLines) {
+				t.Fatalf("Expected errors on lines %v, got %v", tt.errLines, err)
+			}
+			for i, line := range tt.errLines {
+				if errs[i].Line != line || errs[i].Reason != ReasonUnexpectedArg {
+					t.Errorf("Expected an unexpected argument on line %d, got %+v", line, errs[i])
+				}
+			}
+		})
+	}
+
+	parser := NewFileParser(strings.NewReader(input[:strings.Index(input, "\n")+1]))
+	managers, err := parser.ReadManagerEvents(&club.Club{MaxTables: 2, Discounts: promo})
+	if err != nil {
+		t.Fatalf("ReadManagerEvents returned error: %v", err)
+	}
+	if managers[0].PromoCode != "welcome" {
+		t.Errorf("Expected the promo code welcome, got %+v", managers[0])
+	}
+}
+
+func clock(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
@@ -0,0 +1,143 @@
+package analytics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/client"
+	"github.com/apartapatia/computer_club_assistant/pkg/handlers"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+var ErrInvalidStep = errors.New("InvalidStep")
+
+// Bucket sums up one slice of a working day. Tables holds the share of the
+// slice every table was occupied, Utilisation the share of all the tables.
+// TurnedAway counts the clients refused with YouShallNotPass or NotOpenYet,
+// Ejected the clients sent away from the queue before closing.
+type Bucket struct {
+	Start       time.Time
+	End         time.Time
+	Tables      []float64
+	Utilisation float64
+	AvgQueue    float64
+	MaxQueue    int
+	TurnedAway  int
+	Ejected     int
+}
+
+type Day struct {
+	Date    time.Time
+	Dated   bool
+	Buckets []Bucket
+}
+
+// Peak returns the bucket with the highest utilisation, the earliest one
+// on a tie.
+func (d *Day) Peak() (Bucket, bool) {
+	if len(d.Buckets) == 0 {
+		return Bucket{}, false
+	}
+
+	peak := d.Buckets[0]
+	for _, b := range d.Buckets[1:] {
+		if b.Utilisation > peak.Utilisation {
+			peak = b
+		}
+	}
+	return peak, true
+}
+
+// StateFunc tells the state of the club at a moment, as
+// CommandHandler.StateAt does.
+type StateFunc func(t time.Time) (handlers.State, bool)
+
+// Build splits every working day of the report into buckets of the given
+// length and samples the state of the club minute by minute. Refusals
+// before opening or after closing count to the first or the last bucket.
+func Build(r *report.Report, tables int, step time.Duration, stateAt StateFunc) ([]Day, error) {
+	if step < time.Minute || step%time.Minute != 0 {
+		return nil, ErrInvalidStep
+	}
+
+	days := make([]Day, 0, len(r.Days))
+	for _, d := range r.Days {
+		days = append(days, buildDay(d, tables, step, stateAt))
+	}
+	return days, nil
+}
+
+func buildDay(d *report.Day, tables int, step time.Duration, stateAt StateFunc) Day {
+	day := Day{Date: d.Date, Dated: d.Dated()}
+
+	for start := d.Open; start.Before(d.Close); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(d.Close) {
+			end = d.Close
+		}
+
+		bucket := Bucket{Start: start, End: end, Tables: make([]float64, tables)}
+		minutes := 0
+		occupied := make([]int, tables)
+		queued := 0
+
+		for t := start; t.Before(end); t = t.Add(time.Minute) {
+			minutes++
+
+			state, ok := stateAt(t)
+			if !ok {
+				continue
+			}
+
+			for i, table := range state.Tables {
+				if i < tables && table.Client != "" {
+					occupied[i]++
+				}
+			}
+
+			queued += len(state.Queue)
+			if len(state.Queue) > bucket.MaxQueue {
+				bucket.MaxQueue = len(state.Queue)
+			}
+		}
+
+		total := 0
+		for i, m := range occupied {
+			bucket.Tables[i] = float64(m) / float64(minutes)
+			total += m
+		}
+		if tables != 0 {
+			bucket.Utilisation = float64(total) / float64(minutes*tables)
+		}
+		bucket.AvgQueue = float64(queued) / float64(minutes)
+
+		day.Buckets = append(day.Buckets, bucket)
+	}
+
+	if len(day.Buckets) == 0 {
+		return day
+	}
+
+	for _, e := range d.Events {
+		if !e.Generated {
+			continue
+		}
+
+		i := int(e.Time.Sub(d.Open) / step)
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(day.Buckets) {
+			i = len(day.Buckets) - 1
+		}
+
+		switch {
+		case e.ID == handlers.OutgoingClientError && (e.Error == client.ErrClientAlreadyExists.Error() || e.Error == handlers.ErrNotOpen.Error()):
+			day.Buckets[i].TurnedAway++
+		case e.ID == handlers.OutgoingClientAfterClose && e.Time.Before(d.Close):
+			day.Buckets[i].Ejected++
+		}
+	}
+
+	return day
+}
@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/client"
+	"github.com/apartapatia/computer_club_assistant/pkg/handlers"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+func clock(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestBuild(t *testing.T) {
+	r := &report.Report{Days: []*report.Day{{
+		Date:  clock(0, 0),
+		Open:  clock(9, 0),
+		Close: clock(11, 0),
+		Events: []report.Event{
+			report.NewErrorEvent(clock(8, 50), handlers.OutgoingClientError, handlers.ErrNotOpen),
+			report.NewErrorEvent(clock(10, 5), handlers.OutgoingClientError, client.ErrClientAlreadyExists),
+			report.NewErrorEvent(clock(10, 6), handlers.OutgoingClientError, client.ErrClientNotFound),
+			report.NewOutgoingEvent(clock(10, 10), handlers.OutgoingClientAfterClose, "client3", 0),
+			report.NewOutgoingEvent(clock(11, 0), handlers.OutgoingClientAfterClose, "client1", 0),
+		},
+	}}}
+
+	// client1 sits at table 1 from 09:30, client2 waits from 10:00 to 10:30.
+	stateAt := func(at time.Time) (handlers.State, bool) {
+		state := handlers.State{Tables: []handlers.TableState{{TableID: 1}, {TableID: 2}}}
+		if !at.Before(clock(9, 30)) {
+			state.Tables[0].Client = "client1"
+		}
+		if !at.Before(clock(10, 0)) && at.Before(clock(10, 30)) {
+			state.Queue = []handlers.QueueState{{Position: 1, Client: "client2"}}
+		}
+		return state, true
+	}
+
+	days, err := Build(r, 2, time.Hour, stateAt)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	buckets := days[0].Buckets
+	if len(buckets) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(buckets))
+	}
+
+	first, second := buckets[0], buckets[1]
+	if first.Tables[0] != 0.5 || first.Utilisation != 0.25 || first.MaxQueue != 0 || first.TurnedAway != 1 {
+		t.Errorf("unexpected first bucket: %+v", first)
+	}
+	if second.Tables[0] != 1 || second.Utilisation != 0.5 || second.AvgQueue != 0.5 || second.MaxQueue != 1 {
+		t.Errorf("unexpected second bucket: %+v", second)
+	}
+	if second.TurnedAway != 1 || second.Ejected != 1 {
+		t.Errorf("expected 1 turned away and 1 ejected client, got %d and %d", second.TurnedAway, second.Ejected)
+	}
+
+	if peak, _ := days[0].Peak(); !peak.Start.Equal(clock(10, 0)) {
+		t.Errorf("expected the peak at 10:00, got %v", peak.Start)
+	}
+
+	var sb strings.Builder
+	if err := WriteText(&sb, days); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	if !strings.Contains(sb.String(), "1     ▓     █\n") || !strings.Contains(sb.String(), "peak 10:00 50%\n") {
+		t.Errorf("unexpected heatmap:\n%s", sb.String())
+	}
+
+	if _, err := Build(r, 2, 90*time.Second, stateAt); !errors.Is(err, ErrInvalidStep) {
+		t.Errorf("expected ErrInvalidStep, got %v", err)
+	}
+}
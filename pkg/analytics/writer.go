@@ -0,0 +1,132 @@
+package analytics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+const cellWidth = 6
+
+var shades = []string{".", "░", "▒", "▓", "█"}
+
+// shade picks the heatmap cell for the share of time a table was occupied:
+// a dot for a free table, then a darker block every quarter.
+func shade(share float64) string {
+	if share <= 0 {
+		return shades[0]
+	}
+
+	level := int(share*4) + 1
+	if level >= len(shades) {
+		level = len(shades) - 1
+	}
+	return shades[level]
+}
+
+// WriteText renders every day as a heatmap: a column per bucket, a row per
+// table, then the utilisation of all the tables, the longest queue, the
+// turned away and the ejected clients, and the peak of the day.
+func WriteText(w io.Writer, days []Day) error {
+	var sb strings.Builder
+
+	for i, d := range days {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if d.Dated {
+			sb.WriteString(d.Date.Format(club.DateFormat) + "\n")
+		}
+		if len(d.Buckets) == 0 {
+			continue
+		}
+
+		writeRow(&sb, "time", d.Buckets, func(b Bucket) string { return b.Start.Format(club.TimeFormat) })
+
+		for table := range d.Buckets[0].Tables {
+			writeRow(&sb, strconv.Itoa(table+1), d.Buckets, func(b Bucket) string { return shade(b.Tables[table]) })
+		}
+
+		writeRow(&sb, "all", d.Buckets, func(b Bucket) string { return fmt.Sprintf("%d%%", percent(b.Utilisation)) })
+		writeRow(&sb, "queue", d.Buckets, func(b Bucket) string { return strconv.Itoa(b.MaxQueue) })
+		writeRow(&sb, "away", d.Buckets, func(b Bucket) string { return strconv.Itoa(b.TurnedAway) })
+		writeRow(&sb, "eject", d.Buckets, func(b Bucket) string { return strconv.Itoa(b.Ejected) })
+
+		if peak, ok := d.Peak(); ok {
+			sb.WriteString(fmt.Sprintf("peak %s %d%%\n", peak.Start.Format(club.TimeFormat), percent(peak.Utilisation)))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeRow(sb *strings.Builder, label string, buckets []Bucket, cell func(Bucket) string) {
+	cells := []string{pad(label)}
+	for _, b := range buckets {
+		cells = append(cells, pad(cell(b)))
+	}
+	sb.WriteString(strings.TrimRight(strings.Join(cells, ""), " ") + "\n")
+}
+
+func pad(s string) string {
+	if n := cellWidth - len([]rune(s)); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s + " "
+}
+
+func percent(share float64) int {
+	return int(share*100 + 0.5)
+}
+
+// WriteCSV writes a row per bucket with the utilisation of every table as
+// a percentage in its own column.
+func WriteCSV(w io.Writer, days []Day) error {
+	tables := 0
+	if len(days) != 0 && len(days[0].Buckets) != 0 {
+		tables = len(days[0].Buckets[0].Tables)
+	}
+
+	header := []string{"date", "start", "end", "utilisation"}
+	for table := 1; table <= tables; table++ {
+		header = append(header, fmt.Sprintf("table_%d", table))
+	}
+	header = append(header, "avg_queue", "max_queue", "turned_away", "ejected")
+
+	cvw := csv.NewWriter(w)
+	if err := cvw.Write(header); err != nil {
+		return err
+	}
+
+	for _, d := range days {
+		date := ""
+		if d.Dated {
+			date = d.Date.Format(club.DateFormat)
+		}
+
+		for _, b := range d.Buckets {
+			row := []string{date, b.Start.Format(club.TimeFormat), b.End.Format(club.TimeFormat), strconv.Itoa(percent(b.Utilisation))}
+			for _, share := range b.Tables {
+				row = append(row, strconv.Itoa(percent(share)))
+			}
+			row = append(row,
+				strconv.FormatFloat(b.AvgQueue, 'f', 2, 64),
+				strconv.Itoa(b.MaxQueue),
+				strconv.Itoa(b.TurnedAway),
+				strconv.Itoa(b.Ejected),
+			)
+
+			if err := cvw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cvw.Flush()
+	return cvw.Error()
+}
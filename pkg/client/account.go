@@ -0,0 +1,84 @@
+package client
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrInvalidAmount   = errors.New("InvalidAmount")
+	ErrAccountNotFound = errors.New("AccountUnknown")
+)
+
+// Account is the prepaid balance of a client. Unlike Client it is kept
+// after the client leaves the club.
+type Account struct {
+	Username string
+	Balance  int
+}
+
+type AccountRepository interface {
+	TopUp(username string, amount int) (int, error)
+	Charge(username string, amount int) (int, error)
+	Balance(username string) (int, bool)
+	GetAll() map[string]*Account
+}
+
+type AccountRepositoryMemory struct {
+	accounts map[string]*Account
+	mu       sync.RWMutex
+}
+
+func (ar *AccountRepositoryMemory) GetAll() map[string]*Account {
+	return ar.accounts
+}
+
+// TopUp adds the amount to the balance of the client, opening the account
+// on the first top-up, and returns the new balance.
+func (ar *AccountRepositoryMemory) TopUp(username string, amount int) (int, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	account, ok := ar.accounts[username]
+	if !ok {
+		account = &Account{Username: username}
+		ar.accounts[username] = account
+	}
+	account.Balance += amount
+	return account.Balance, nil
+}
+
+// Charge takes the amount from the balance of the client and returns the
+// new balance. The balance may go below zero.
+func (ar *AccountRepositoryMemory) Charge(username string, amount int) (int, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	account, ok := ar.accounts[username]
+	if !ok {
+		return 0, ErrAccountNotFound
+	}
+	account.Balance -= amount
+	return account.Balance, nil
+}
+
+func (ar *AccountRepositoryMemory) Balance(username string) (int, bool) {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+
+	account, ok := ar.accounts[username]
+	if !ok {
+		return 0, false
+	}
+	return account.Balance, true
+}
+
+func NewAccountMemoryRepo() *AccountRepositoryMemory {
+	return &AccountRepositoryMemory{
+		accounts: make(map[string]*Account),
+	}
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestAccountRepository(t *testing.T) {
+	repo := NewAccountMemoryRepo()
+
+	if _, err := repo.Charge("anna", 10); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("expected %v without an account, got %v", ErrAccountNotFound, err)
+	}
+	if _, err := repo.TopUp("anna", 0); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected %v for an empty top-up, got %v", ErrInvalidAmount, err)
+	}
+	if _, ok := repo.Balance("anna"); ok {
+		t.Errorf("expected no account after a rejected top-up")
+	}
+
+	if balance, err := repo.TopUp("anna", 30); err != nil || balance != 30 {
+		t.Fatalf("TopUp: expected 30, got %d, %v", balance, err)
+	}
+	if balance, err := repo.TopUp("anna", 20); err != nil || balance != 50 {
+		t.Fatalf("TopUp: expected 50, got %d, %v", balance, err)
+	}
+	if balance, err := repo.Charge("anna", 40); err != nil || balance != 10 {
+		t.Fatalf("Charge: expected 10, got %d, %v", balance, err)
+	}
+
+	// The balance may go below zero, the next top-up pays the debt off.
+	if balance, err := repo.Charge("anna", 25); err != nil || balance != -15 {
+		t.Fatalf("Charge: expected -15, got %d, %v", balance, err)
+	}
+	if balance, ok := repo.Balance("anna"); !ok || balance != -15 {
+		t.Errorf("expected a balance of -15, got %d", balance)
+	}
+	if balance, err := repo.TopUp("anna", 20); err != nil || balance != 5 {
+		t.Errorf("TopUp: expected 5, got %d, %v", balance, err)
+	}
+}
+
+func TestAccountBoltRepoRecoversBalances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "club.db")
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	repo, err := NewAccountBoltRepo(db)
+	if err != nil {
+		t.Fatalf("NewAccountBoltRepo: %v", err)
+	}
+
+	if _, err := repo.TopUp("anna", 50); err != nil {
+		t.Fatalf("TopUp: %v", err)
+	}
+	if _, err := repo.Charge("anna", 30); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	if _, err := repo.TopUp("boris", 10); err != nil {
+		t.Fatalf("TopUp: %v", err)
+	}
+	if _, err := repo.Charge("boris", 25); err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	db.Close()
+
+	db, err = bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer db.Close()
+
+	repo, err = NewAccountBoltRepo(db)
+	if err != nil {
+		t.Fatalf("NewAccountBoltRepo: %v", err)
+	}
+
+	if balance, ok := repo.Balance("anna"); !ok || balance != 20 {
+		t.Errorf("expected anna to have 20 left, got %d", balance)
+	}
+	if balance, ok := repo.Balance("boris"); !ok || balance != -15 {
+		t.Errorf("expected boris to owe 15, got %d", balance)
+	}
+	if len(repo.GetAll()) != 2 {
+		t.Errorf("expected 2 accounts, got %d", len(repo.GetAll()))
+	}
+}
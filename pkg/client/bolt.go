@@ -0,0 +1,253 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var clientsBucket = []byte("clients")
+
+// ClientRepositoryBolt keeps the clients in memory and writes every change
+// through to a bbolt database, so the clients in the club, and the moment
+// the waiting ones joined the queue, survive a restart.
+type ClientRepositoryBolt struct {
+	*ClientRepositoryMemory
+	db *bolt.DB
+}
+
+func (cr *ClientRepositoryBolt) Add(client *Client) error {
+	if err := cr.ClientRepositoryMemory.Add(client); err != nil {
+		return err
+	}
+	return cr.put(client)
+}
+
+func (cr *ClientRepositoryBolt) Remove(username string) error {
+	if err := cr.ClientRepositoryMemory.Remove(username); err != nil {
+		return err
+	}
+
+	return cr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(clientsBucket).Delete([]byte(username))
+	})
+}
+
+func (cr *ClientRepositoryBolt) UpdateStatus(username string, newStatus int) error {
+	if err := cr.ClientRepositoryMemory.UpdateStatus(username, newStatus); err != nil {
+		return err
+	}
+
+	client, err := cr.Get(username)
+	if err != nil {
+		return err
+	}
+	return cr.put(client)
+}
+
+func (cr *ClientRepositoryBolt) MarkWaiting(username string, since time.Time) error {
+	if err := cr.ClientRepositoryMemory.MarkWaiting(username, since); err != nil {
+		return err
+	}
+
+	client, err := cr.Get(username)
+	if err != nil {
+		return err
+	}
+	return cr.put(client)
+}
+
+func (cr *ClientRepositoryBolt) put(client *Client) error {
+	data, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+
+	return cr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(clientsBucket).Put([]byte(client.Username), data)
+	})
+}
+
+// NewBoltRepo opens the clients stored in db, creating the bucket on first
+// use.
+func NewBoltRepo(db *bolt.DB) (*ClientRepositoryBolt, error) {
+	cr := &ClientRepositoryBolt{
+		ClientRepositoryMemory: NewMemoryRepo(),
+		db:                     db,
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(clientsBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(_, data []byte) error {
+			client := &Client{}
+			if err := json.Unmarshal(data, client); err != nil {
+				return err
+			}
+			cr.clients[client.Username] = client
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+var accountsBucket = []byte("accounts")
+
+// AccountRepositoryBolt keeps the prepaid balances in memory and writes
+// every change through to a bbolt database.
+type AccountRepositoryBolt struct {
+	*AccountRepositoryMemory
+	db *bolt.DB
+}
+
+func (ar *AccountRepositoryBolt) TopUp(username string, amount int) (int, error) {
+	balance, err := ar.AccountRepositoryMemory.TopUp(username, amount)
+	if err != nil {
+		return 0, err
+	}
+	return balance, ar.put(username)
+}
+
+func (ar *AccountRepositoryBolt) Charge(username string, amount int) (int, error) {
+	balance, err := ar.AccountRepositoryMemory.Charge(username, amount)
+	if err != nil {
+		return 0, err
+	}
+	return balance, ar.put(username)
+}
+
+func (ar *AccountRepositoryBolt) put(username string) error {
+	ar.mu.RLock()
+	data, err := json.Marshal(ar.accounts[username])
+	ar.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return ar.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(accountsBucket).Put([]byte(username), data)
+	})
+}
+
+// NewAccountBoltRepo opens the accounts stored in db, creating the bucket
+// on first use.
+func NewAccountBoltRepo(db *bolt.DB) (*AccountRepositoryBolt, error) {
+	ar := &AccountRepositoryBolt{
+		AccountRepositoryMemory: NewAccountMemoryRepo(),
+		db:                      db,
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(accountsBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(_, data []byte) error {
+			account := &Account{}
+			if err := json.Unmarshal(data, account); err != nil {
+				return err
+			}
+			ar.accounts[account.Username] = account
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ar, nil
+}
+
+var (
+	promoCodesBucket = []byte("promo_codes")
+	queuedInBucket   = []byte("queued_in")
+	clubBucket       = []byte("club")
+	initialisedKey   = []byte("initialised")
+)
+
+// VisitRepositoryBolt keeps the used promo codes, the clients seated from
+// the queue and the initialised mark in memory and writes every change
+// through to a bbolt database.
+type VisitRepositoryBolt struct {
+	*VisitRepositoryMemory
+	db *bolt.DB
+}
+
+func (vr *VisitRepositoryBolt) UsePromoCode(code string) error {
+	if err := vr.VisitRepositoryMemory.UsePromoCode(code); err != nil {
+		return err
+	}
+
+	return vr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(promoCodesBucket).Put([]byte(code), []byte{1})
+	})
+}
+
+func (vr *VisitRepositoryBolt) SetQueuedIn(username string, queuedIn bool) error {
+	if err := vr.VisitRepositoryMemory.SetQueuedIn(username, queuedIn); err != nil {
+		return err
+	}
+
+	return vr.db.Update(func(tx *bolt.Tx) error {
+		if queuedIn {
+			return tx.Bucket(queuedInBucket).Put([]byte(username), []byte{1})
+		}
+		return tx.Bucket(queuedInBucket).Delete([]byte(username))
+	})
+}
+
+func (vr *VisitRepositoryBolt) MarkInitialised() error {
+	if err := vr.VisitRepositoryMemory.MarkInitialised(); err != nil {
+		return err
+	}
+
+	return vr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(clubBucket).Put(initialisedKey, []byte{1})
+	})
+}
+
+// NewVisitBoltRepo opens the visits stored in db, creating the buckets on
+// first use.
+func NewVisitBoltRepo(db *bolt.DB) (*VisitRepositoryBolt, error) {
+	vr := &VisitRepositoryBolt{
+		VisitRepositoryMemory: NewVisitMemoryRepo(),
+		db:                    db,
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{promoCodesBucket, queuedInBucket, clubBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		vr.initialised = tx.Bucket(clubBucket).Get(initialisedKey) != nil
+
+		err := tx.Bucket(promoCodesBucket).ForEach(func(code, _ []byte) error {
+			vr.used[string(code)] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(queuedInBucket).ForEach(func(username, _ []byte) error {
+			vr.queuedIn[string(username)] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vr, nil
+}
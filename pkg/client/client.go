@@ -3,15 +3,19 @@ package client
 import (
 	"errors"
 	"regexp"
+	"time"
 )
 
 var (
 	ErrValidationName = errors.New("BadValidationName")
 )
 
+// Client is a client in the club. EnqueuedAt is when a client in the queue
+// started waiting.
 type Client struct {
-	Username string
-	State    int
+	Username   string
+	State      int
+	EnqueuedAt time.Time
 }
 
 func ValidateUsername(username string) (bool, error) {
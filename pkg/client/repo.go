@@ -2,8 +2,8 @@ package client
 
 import (
 	"errors"
-	"sort"
 	"sync"
+	"time"
 )
 
 const QueueState = 3
@@ -20,7 +20,7 @@ type ClientRepository interface {
 	Get(username string) (*Client, error)
 	Remove(username string) error
 	UpdateStatus(username string, newStatus int) error
-	Queue() []*Client
+	MarkWaiting(username string, since time.Time) error
 	GetAll() map[string]*Client
 }
 
@@ -68,28 +68,6 @@ func (cr *ClientRepositoryMemory) Get(username string) (*Client, error) {
 	return client, nil
 }
 
-func (cr *ClientRepositoryMemory) Queue() []*Client {
-	cr.mu.RLock()
-	defer cr.mu.RUnlock()
-
-	var keys []string
-	for key := range cr.clients {
-		keys = append(keys, key)
-	}
-	sort.Strings(keys)
-
-	var queueClients []*Client
-
-	for _, key := range keys {
-		client := cr.clients[key]
-		if client.State == QueueState {
-			queueClients = append(queueClients, client)
-		}
-	}
-
-	return queueClients
-}
-
 func (cr *ClientRepositoryMemory) Remove(username string) error {
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
@@ -113,6 +91,21 @@ func (cr *ClientRepositoryMemory) UpdateStatus(username string, newStatus int) e
 	return nil
 }
 
+// MarkWaiting puts the client in the queue state, waiting since the given
+// moment.
+func (cr *ClientRepositoryMemory) MarkWaiting(username string, since time.Time) error {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	client, ok := cr.clients[username]
+	if !ok {
+		return ErrClientNotFound
+	}
+	client.State = QueueState
+	client.EnqueuedAt = since
+	return nil
+}
+
 func NewMemoryRepo() *ClientRepositoryMemory {
 	return &ClientRepositoryMemory{
 		clients: make(map[string]*Client),
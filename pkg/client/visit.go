@@ -0,0 +1,81 @@
+package client
+
+import "sync"
+
+// VisitRepository keeps what the club remembers about the visits beyond the
+// clients in it: the promo codes used up, for good, and the clients seated
+// from the queue, until their session ends. Initialised marks a repository
+// that already holds the events of the input.
+type VisitRepository interface {
+	PromoCodeUsed(code string) bool
+	UsePromoCode(code string) error
+	QueuedIn(username string) bool
+	SetQueuedIn(username string, queuedIn bool) error
+	Initialised() bool
+	MarkInitialised() error
+}
+
+type VisitRepositoryMemory struct {
+	used        map[string]bool
+	queuedIn    map[string]bool
+	initialised bool
+	mu          sync.RWMutex
+}
+
+func (vr *VisitRepositoryMemory) PromoCodeUsed(code string) bool {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+
+	return vr.used[code]
+}
+
+func (vr *VisitRepositoryMemory) UsePromoCode(code string) error {
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+
+	vr.used[code] = true
+	return nil
+}
+
+func (vr *VisitRepositoryMemory) QueuedIn(username string) bool {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+
+	return vr.queuedIn[username]
+}
+
+// SetQueuedIn marks the client seated from the queue, or forgets the mark
+// when the session ends.
+func (vr *VisitRepositoryMemory) SetQueuedIn(username string, queuedIn bool) error {
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+
+	if queuedIn {
+		vr.queuedIn[username] = true
+	} else {
+		delete(vr.queuedIn, username)
+	}
+	return nil
+}
+
+func (vr *VisitRepositoryMemory) Initialised() bool {
+	vr.mu.RLock()
+	defer vr.mu.RUnlock()
+
+	return vr.initialised
+}
+
+func (vr *VisitRepositoryMemory) MarkInitialised() error {
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+
+	vr.initialised = true
+	return nil
+}
+
+func NewVisitMemoryRepo() *VisitRepositoryMemory {
+	return &VisitRepositoryMemory{
+		used:     make(map[string]bool),
+		queuedIn: make(map[string]bool),
+	}
+}
@@ -0,0 +1,26 @@
+package club
+
+// StandardCategory is the category of tables not listed in any other one.
+const StandardCategory = "standard"
+
+// TableCategory groups tables sharing an hourly price, e.g. VIP rooms or
+// console booths.
+type TableCategory struct {
+	Name   string
+	Price  int
+	Tables []int
+}
+
+// FindCategory returns the category the table belongs to. Tables outside
+// every category get the standard one with a zero price, meaning the price
+// of the club.
+func FindCategory(categories []TableCategory, tableID int) TableCategory {
+	for _, category := range categories {
+		for _, id := range category.Tables {
+			if id == tableID {
+				return category
+			}
+		}
+	}
+	return TableCategory{Name: StandardCategory}
+}
@@ -1,9 +1,50 @@
 package club
 
+import "time"
+
+// DefaultMaxPause is how long a client may be away from the table before
+// the club gives it up.
+const DefaultMaxPause = 30 * time.Minute
+
 type Club struct {
 	WorkingTime *WorkingTime
 	Price       int
 	MaxTables   int
+	Queue       *QueueConfig
+	Billing     *BillingConfig
+	Tariffs     []TariffBand
+	Categories  []TableCategory
+	Discounts   *Discounts
+	Packages    []TimePackage
+
+	ReservationGrace time.Duration
+	// MaxPause of zero lets a client be away for as long as the club is
+	// open.
+	MaxPause time.Duration
+}
+
+// BillingConfig selects how sessions are charged. Block is the length of a
+// charged block for the block strategy and Grace the uncharged remainder.
+type BillingConfig struct {
+	Strategy string
+	Block    time.Duration
+	Grace    time.Duration
+}
+
+// QueueConfig describes how the waiting queue of the club is served. A zero
+// Capacity means the queue may be as long as the number of tables.
+type QueueConfig struct {
+	Capacity         int
+	Policy           string
+	VIPClients       map[string]bool
+	ExpectedSessions map[string]time.Duration
+}
+
+func (c *Club) QueueCapacity() int {
+	if c.Queue == nil || c.Queue.Capacity == 0 {
+		return c.MaxTables
+	}
+	return c.Queue.Capacity
 }
 
 func NewClub(workingTime *WorkingTime, price, tablesCount int) *Club {
@@ -11,5 +52,10 @@ func NewClub(workingTime *WorkingTime, price, tablesCount int) *Club {
 		WorkingTime: workingTime,
 		Price:       price,
 		MaxTables:   tablesCount,
+		Queue:       &QueueConfig{},
+		Billing:     &BillingConfig{},
+
+		ReservationGrace: DefaultReservationGrace,
+		MaxPause:         DefaultMaxPause,
 	}
 }
@@ -0,0 +1,71 @@
+package club
+
+// Discounts lists the price reductions of the club. A nil Discounts means
+// every session is charged in full.
+type Discounts struct {
+	Memberships []Membership
+	HappyHours  []HappyHour
+	Bundles     []Bundle
+	PromoCodes  []PromoCode
+}
+
+// Membership is a tier of regular clients with a percentage off every
+// session.
+type Membership struct {
+	Name    string
+	Percent int
+	Clients []string
+}
+
+// HappyHour takes a percentage off the time charged within a time window.
+type HappyHour struct {
+	Name string
+	TimeWindow
+	Percent int
+}
+
+// Bundle charges every Hours hours of a session as Paid hours, e.g. three
+// hours for the price of two.
+type Bundle struct {
+	Name  string
+	Hours int
+	Paid  int
+}
+
+// PromoCode is a one-off code a client brings on arrival for a percentage
+// off the sessions of that visit.
+type PromoCode struct {
+	Code    string
+	Percent int
+}
+
+// MembershipPercent returns the discount of the best tier the client is a
+// member of.
+func (d *Discounts) MembershipPercent(username string) int {
+	if d == nil {
+		return 0
+	}
+
+	percent := 0
+	for _, m := range d.Memberships {
+		for _, name := range m.Clients {
+			if name == username && m.Percent > percent {
+				percent = m.Percent
+			}
+		}
+	}
+	return percent
+}
+
+func (d *Discounts) FindPromoCode(code string) (PromoCode, bool) {
+	if d == nil {
+		return PromoCode{}, false
+	}
+
+	for _, promo := range d.PromoCodes {
+		if promo.Code == code {
+			return promo, true
+		}
+	}
+	return PromoCode{}, false
+}
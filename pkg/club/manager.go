@@ -7,11 +7,34 @@ import (
 	"github.com/apartapatia/computer_club_assistant/pkg/client"
 )
 
+// Ids of the incoming events. Booking takes a table or a category, the start
+// and the length instead of a table, a top-up the amount and a package
+// purchase the name of the package; an arrival may carry a promo code.
+const (
+	IncomingClientCome          = 1
+	IncomingClientTookTheTable  = 2
+	IncomingClientIsWaiting     = 3
+	IncomingClientLeft          = 4
+	IncomingClientBooked        = 5
+	IncomingClientCancelled     = 6
+	IncomingClientToppedUp      = 7
+	IncomingClientBoughtPackage = 8
+	IncomingClientPaused        = 9
+	IncomingClientResumed       = 10
+)
+
+// Manager is a single incoming event. Reservation is set for the booking
+// event only, Amount for the top-up of a prepaid balance, PromoCode for an
+// arrival with a promo code and Package for the purchase of a package.
 type Manager struct {
-	Time    time.Time
-	ID      int
-	Client  *client.Client
-	TableID int
+	Time        time.Time
+	ID          int
+	Client      *client.Client
+	TableID     int
+	Amount      int
+	PromoCode   string
+	Package     string
+	Reservation *Reservation
 }
 
 func (m *Manager) String() string {
@@ -22,18 +45,6 @@ func (m *Manager) String() string {
 	}
 }
 
-func (m *Manager) ErrorString(err error, ID int) string {
-	return fmt.Sprintf("%s %d %s\n", m.Time.Format(TimeFormat), ID, err)
-}
-
-func (m *Manager) OutgoingString(idEvent int, username string, tableID int) string {
-	if idEvent == 12 {
-		return fmt.Sprintf("%s %d %s %d\n", m.Time.Format(TimeFormat), idEvent, username, tableID)
-	} else {
-		return fmt.Sprintf("%s %d %s\n", m.Time.Format(TimeFormat), idEvent, username)
-	}
-}
-
 func NewManager(time time.Time, id int, clientName string, tableID int) *Manager {
 	return &Manager{
 		Time: time,
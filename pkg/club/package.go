@@ -0,0 +1,18 @@
+package club
+
+// TimePackage is a fixed price for the time within a time window, e.g. a
+// night pass from 22:00 to 08:00.
+type TimePackage struct {
+	Name string
+	TimeWindow
+	Price int
+}
+
+func FindPackage(packages []TimePackage, name string) (TimePackage, bool) {
+	for _, p := range packages {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return TimePackage{}, false
+}
@@ -0,0 +1,43 @@
+package club
+
+import "time"
+
+// DefaultReservationGrace is how long a reserved table is held for a client
+// who is late.
+const DefaultReservationGrace = 15 * time.Minute
+
+// Reservation is a booking of a table, or of any table of a category, for
+// a period of the day. The table is held for the client until Grace after
+// the start; a client who does not come by then loses the booking. Arrived
+// marks a client who came while the table was still taken by someone else:
+// the booking is kept for them to the end.
+type Reservation struct {
+	Client   string
+	TableID  int
+	Category string
+	Start    time.Time
+	Duration time.Duration
+	Grace    time.Duration
+	Arrived  bool
+}
+
+func (r *Reservation) End() time.Time {
+	return r.Start.Add(r.Duration)
+}
+
+// Deadline is the last moment the client may come to the reserved table.
+func (r *Reservation) Deadline() time.Time {
+	if r.Grace > r.Duration {
+		return r.End()
+	}
+	return r.Start.Add(r.Grace)
+}
+
+// Holds reports whether the table is reserved at the moment.
+func (r *Reservation) Holds(t time.Time) bool {
+	return !t.Before(r.Start) && t.Before(r.End())
+}
+
+func (r *Reservation) Overlaps(other *Reservation) bool {
+	return r.Start.Before(other.End()) && other.Start.Before(r.End())
+}
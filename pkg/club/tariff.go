@@ -0,0 +1,31 @@
+package club
+
+import "time"
+
+// TimeWindow is the time between two clock times of any day. A window whose
+// end is before its start runs over midnight.
+type TimeWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+func (w TimeWindow) Contains(t time.Time) bool {
+	m := minuteOfDay(t)
+	from, to := minuteOfDay(w.From), minuteOfDay(w.To)
+
+	if from <= to {
+		return m >= from && m < to
+	}
+	return m >= from || m < to
+}
+
+// TariffBand is an hourly price applied within a time window.
+type TariffBand struct {
+	Name string
+	TimeWindow
+	Price int
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
@@ -2,8 +2,14 @@ package club
 
 import "time"
 
-const TimeFormat = "15:04"
+const (
+	TimeFormat     = "15:04"
+	DateFormat     = "2006-01-02"
+	DateTimeFormat = DateFormat + " " + TimeFormat
+)
 
+// WorkingTime holds the opening and closing clock times of the club. A
+// Close before Open means the club works overnight and closes the next day.
 type WorkingTime struct {
 	Open  time.Time
 	Close time.Time
@@ -16,10 +22,49 @@ func NewWorkingTime(open, close time.Time) *WorkingTime {
 	}
 }
 
-func IsTimeWithinWorkingHours(workingTime WorkingTime, time time.Time) bool {
-	if workingTime.Open.After(workingTime.Close) {
-		return !time.Before(workingTime.Open) || !time.After(workingTime.Close)
+func (wt *WorkingTime) Overnight() bool {
+	return wt.Open.After(wt.Close)
+}
+
+// End is the moment the club closes, on the next day for overnight clubs.
+func (wt *WorkingTime) End() time.Time {
+	if wt.Overnight() {
+		return wt.Close.Add(24 * time.Hour)
 	}
+	return wt.Close
+}
+
+// Normalize moves a clock time between midnight and closing of an overnight
+// club to the next day, so events keep their order and durations across
+// midnight. Times between closing and opening are taken as before opening.
+func (wt *WorkingTime) Normalize(t time.Time) time.Time {
+	if wt.Overnight() && !t.After(wt.Close) {
+		return t.Add(24 * time.Hour)
+	}
+	return t
+}
+
+// DayOf returns the working day the moment belongs to: the date the club
+// opened on. Moments after midnight of an overnight club belong to the
+// previous day.
+func (wt *WorkingTime) DayOf(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if wt.Overnight() && clockOf(t) <= clockOf(wt.Close) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// OpenAt is the moment the club opens on the given day.
+func (wt *WorkingTime) OpenAt(day time.Time) time.Time {
+	return day.Add(clockOf(wt.Open))
+}
+
+// CloseAt is the moment the club closes on the given day.
+func (wt *WorkingTime) CloseAt(day time.Time) time.Time {
+	return day.Add(wt.End().Sub(wt.Open) + clockOf(wt.Open))
+}
 
-	return !time.Before(workingTime.Open) && !time.After(workingTime.Close)
+func clockOf(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
 }
@@ -2,29 +2,51 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/apartapatia/computer_club_assistant/pkg/client"
 	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/queue"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
 	"github.com/apartapatia/computer_club_assistant/pkg/table"
 )
 
 var (
 	ErrClientIsWaiting = errors.New("ICanWaitNoLonger!")
 	ErrNotOpen         = errors.New("NotOpenYet")
+	ErrManagerIsNil    = errors.New("ManagerIsNil")
+	ErrUnknownEvent    = errors.New("UnknownEvent")
+	ErrEventOutOfOrder = errors.New("EventOutOfOrder")
+	ErrMixedDates      = errors.New("MixedDatedAndUndatedEvents")
+
+	ErrReservationMissing = errors.New("ReservationMissing")
+	ErrReservationInPast  = errors.New("ReservationInPast")
+
+	ErrPromoCodeUnknown = errors.New("PromoCodeUnknown")
+	ErrPromoCodeUsed    = errors.New("PromoCodeUsed")
+
+	ErrPackageMissing = errors.New("PackageMissing")
+	ErrPackageUnknown = errors.New("PackageUnknown")
 )
 
-const (
-	IncomingClientCome         = 1
-	IncomingClientTookTheTable = 2
-	IncomingClientIsWaiting    = 3
-	IncomingClientLeft         = 4
+// Journal records what the handler accepts and generates, so that the
+// state of the club can be rebuilt after a crash.
+type Journal interface {
+	Incoming(m *club.Manager) error
+	Generated(events []report.Event) error
+	CloseDay(day time.Time) error
+}
 
+const (
 	OutgoingClientAfterClose               = 11
 	OutgoingClientTokeTheTableAfterWaiting = 12
 	OutgoingClientError                    = 13
+	OutgoingReservationReleased            = 14
+	OutgoingBalanceRunOut                  = 15
+	OutgoingPauseExpired                   = 16
+	OutgoingReservationMoved               = 17
 )
 
 type CommandHandler struct {
@@ -32,161 +54,892 @@ type CommandHandler struct {
 	Managers []*club.Manager
 	Clients  client.ClientRepository
 	Tables   table.TableRepository
+	Queue    queue.Queue
+	Accounts client.AccountRepository
+	Visits   client.VisitRepository
+	Updates  *Broadcaster
+	Journal  Journal
+
+	// HistoryDays limits the states kept for StateAt to the latest closed
+	// working days and the day in progress; zero keeps them all.
+	HistoryDays int
+
+	days    []*report.Day
+	day     *report.Day
+	before  map[int]table.Table
+	last    time.Time
+	expired time.Time
+	history []State
+	ledger  map[string]*report.LedgerEntry
 }
 
+// OutgoingEvent is an event generated by the club in response to an
+// incoming one: a client sent away (11), seated from the queue (12), an
+// error (13), a reservation released after a no-show (14), a session ended
+// when the prepaid balance ran out (15), a table given up after too long a
+// pause (16) or a client seated at another table than the one reserved,
+// still taken (17).
+type OutgoingEvent = report.Event
+
+// HandleCommands processes the log and renders it in the text layout.
 func (h *CommandHandler) HandleCommands() string {
 	var sb strings.Builder
-	sb.WriteString(h.Club.WorkingTime.Open.Format(club.TimeFormat) + "\n")
+	if err := report.NewWriter(report.FormatText).Write(&sb, h.Report()); err != nil {
+		return err.Error()
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
 
+// Report processes the log day by day, closing the club at the end of every
+// working day, and returns the events with per-day and period summaries.
+// An event the handler rejects is reported with the reason as an error.
+func (h *CommandHandler) Report() *report.Report {
 	for _, m := range h.Managers {
-		if !club.IsTimeWithinWorkingHours(*h.Club.WorkingTime, m.Time) {
-			sb.WriteString(m.String())
-			sb.WriteString(m.ErrorString(ErrNotOpen, OutgoingClientError))
-			continue
+		if _, err := h.HandleEvent(m); err != nil {
+			h.reject(m, err)
 		}
+	}
+
+	if h.day == nil && len(h.days) == 0 {
+		h.openDay(h.Club.WorkingTime.DayOf(h.Club.WorkingTime.Open))
+	}
+	h.CloseDay()
+
+	return h.report()
+}
+
+// reject records an event HandleEvent refused in the working day in
+// progress, followed by the error.
+func (h *CommandHandler) reject(m *club.Manager, err error) {
+	if m == nil || m.Client == nil {
+		return
+	}
+
+	if h.day == nil {
+		h.openDay(h.Club.WorkingTime.DayOf(m.Time))
+		h.last = m.Time
+	}
+	h.day.Events = append(h.day.Events, report.NewIncomingEvent(m), report.NewErrorEvent(m.Time, OutgoingClientError, err))
+}
+
+// report returns the days processed so far. The day in progress is
+// summarised without closing the club.
+func (h *CommandHandler) report() *report.Report {
+	r := &report.Report{Days: append([]*report.Day(nil), h.days...)}
+
+	if h.day != nil {
+		current := *h.day
+		current.Events = append([]report.Event(nil), h.day.Events...)
+		current.Sessions = append([]table.Session(nil), h.day.Sessions...)
+		current.Tables = h.calculateRevenue(h.before)
+		current.Categories = h.categorySummaries(current.Tables)
+		current.Clients = h.clientSummaries(current.Events, current.Sessions)
+		current.Ledger = h.ledgerEntries()
+		r.Days = append(r.Days, &current)
+	}
+
+	r.Tables = h.calculateRevenue(nil)
+	r.Categories = h.categorySummaries(r.Tables)
+
+	var events []report.Event
+	var sessions []table.Session
+	for _, d := range r.Days {
+		events = append(events, d.Events...)
+		sessions = append(sessions, d.Sessions...)
+	}
+	r.Clients = h.clientSummaries(events, sessions)
+	return r
+}
+
+// openDay starts a working day. The first day counts everything the tables
+// hold, including the sessions recovered from a persistent repository.
+func (h *CommandHandler) openDay(day time.Time) {
+	h.before = nil
+	if len(h.days) > 0 {
+		h.before = h.tableTotals()
+	}
+	h.ledger = make(map[string]*report.LedgerEntry)
+	h.day = &report.Day{
+		Date:  day,
+		Open:  h.Club.WorkingTime.OpenAt(day),
+		Close: h.Club.WorkingTime.CloseAt(day),
+	}
+}
+
+// CloseDay sends the remaining clients away and summarises the working day
+// in progress. It returns nil when no day is open.
+func (h *CommandHandler) CloseDay() *report.Day {
+	if h.day == nil {
+		return nil
+	}
+
+	if h.Journal != nil {
+		_ = h.Journal.CloseDay(h.day.Date)
+	}
+	return h.closeDay()
+}
 
+// closeDay ends the working day in progress. The days closed by an event
+// of the next day are not journaled: replaying that event closes them again.
+func (h *CommandHandler) closeDay() *report.Day {
+	day := h.day
+	before := h.tableStates()
+	events := append(h.expire(day.Close), h.checkLastClient(day.Close)...)
+	day.Events = append(day.Events, events...)
+	if h.Journal != nil {
+		_ = h.Journal.Generated(events)
+	}
+	day.Tables = h.calculateRevenue(h.before)
+	day.Categories = h.categorySummaries(day.Tables)
+	day.Clients = h.clientSummaries(day.Events, day.Sessions)
+	day.Ledger = h.ledgerEntries()
+
+	h.days = append(h.days, day)
+	h.day = nil
+	h.publish(events, before)
+	h.record(day.Close)
+	h.trimHistory()
+	return day
+}
+
+// HandleEvent processes a single incoming event and returns the events the
+// club generated in response. An event of the next working day closes the
+// previous one first. Events must come in time order, all with dates or all
+// without; an event that cannot be processed at all is rejected with an
+// error and not recorded.
+func (h *CommandHandler) HandleEvent(m *club.Manager) ([]OutgoingEvent, error) {
+	if m == nil || m.Client == nil {
+		return nil, ErrManagerIsNil
+	}
+
+	if m.ID < club.IncomingClientCome || m.ID > club.IncomingClientResumed {
+		return nil, ErrUnknownEvent
+	}
+
+	if m.ID == club.IncomingClientToppedUp && m.Amount <= 0 {
+		return nil, client.ErrInvalidAmount
+	}
+
+	if m.ID == club.IncomingClientBooked && m.Reservation == nil {
+		return nil, ErrReservationMissing
+	}
+
+	if m.ID == club.IncomingClientBoughtPackage && m.Package == "" {
+		return nil, ErrPackageMissing
+	}
+
+	started := h.day != nil || len(h.days) > 0
+	if started && (m.Time.Year() != 0) != (h.last.Year() != 0) {
+		return nil, ErrMixedDates
+	}
+
+	if started && m.Time.Before(h.last) {
+		return nil, ErrEventOutOfOrder
+	}
+
+	if h.Journal != nil {
+		if err := h.Journal.Incoming(m); err != nil {
+			return nil, err
+		}
+	}
+	h.last = m.Time
+
+	var outgoing []OutgoingEvent
+	for _, e := range h.handleEvent(m) {
+		if e.Generated {
+			outgoing = append(outgoing, e)
+		}
+	}
+
+	if h.Journal != nil {
+		return outgoing, h.Journal.Generated(outgoing)
+	}
+	return outgoing, nil
+}
+
+// Dated reports whether the events handled so far carry dates. A handler
+// that has not handled any event yet takes dated events.
+func (h *CommandHandler) Dated() bool {
+	started := h.day != nil || len(h.days) > 0
+	return !started || h.last.Year() != 0
+}
+
+func (h *CommandHandler) handleEvent(m *club.Manager) []report.Event {
+	date := h.Club.WorkingTime.DayOf(m.Time)
+	if h.day != nil && !h.day.Date.Equal(date) {
+		h.closeDay()
+	}
+
+	if h.day == nil {
+		h.openDay(date)
+	}
+
+	before := h.tableStates()
+
+	events := h.expire(m.Time)
+	if m.Time.Before(h.day.Open) || m.Time.After(h.day.Close) {
+		events = append(events, report.NewIncomingEvent(m), report.NewErrorEvent(m.Time, OutgoingClientError, ErrNotOpen))
+	} else {
 		switch m.ID {
-		case IncomingClientCome:
-			sb.WriteString(h.handleIncomingClientCome(m))
-		case IncomingClientTookTheTable:
-			sb.WriteString(h.handleIncomingClientTookTheTable(m))
-		case IncomingClientIsWaiting:
-			sb.WriteString(h.handleIncomingClientIsWaiting(m))
-		case IncomingClientLeft:
-			sb.WriteString(h.handleIncomingClientLeft(m))
+		case club.IncomingClientCome:
+			events = append(events, h.handleIncomingClientCome(m)...)
+		case club.IncomingClientTookTheTable:
+			events = append(events, h.handleIncomingClientTookTheTable(m)...)
+		case club.IncomingClientIsWaiting:
+			events = append(events, h.handleIncomingClientIsWaiting(m)...)
+		case club.IncomingClientLeft:
+			events = append(events, h.handleIncomingClientLeft(m)...)
+		case club.IncomingClientBooked:
+			events = append(events, h.handleIncomingClientBooked(m)...)
+		case club.IncomingClientCancelled:
+			events = append(events, h.handleIncomingClientCancelled(m)...)
+		case club.IncomingClientToppedUp:
+			events = append(events, h.handleIncomingClientToppedUp(m)...)
+		case club.IncomingClientBoughtPackage:
+			events = append(events, h.handleIncomingClientBoughtPackage(m)...)
+		case club.IncomingClientPaused:
+			events = append(events, h.handleIncomingClientPaused(m)...)
+		case club.IncomingClientResumed:
+			events = append(events, h.handleIncomingClientResumed(m)...)
 		}
 	}
 
-	sb.WriteString(h.checkLastClient())
-	sb.WriteString(h.Club.WorkingTime.Close.Format(club.TimeFormat) + "\n")
-	sb.WriteString(h.calculateRevenue())
-	return sb.String()[:sb.Len()-1]
+	h.day.Events = append(h.day.Events, events...)
+	h.publish(events, before)
+	h.record(m.Time)
+	return events
 }
 
-func (h *CommandHandler) handleIncomingClientCome(manager *club.Manager) string {
-	var sb strings.Builder
-	sb.WriteString(manager.String())
+func errorEvent(manager *club.Manager, err error) report.Event {
+	return report.NewErrorEvent(manager.Time, OutgoingClientError, err)
+}
+
+// handleIncomingClientCome lets the client in. A promo code brought along
+// is used up by the visit; a client with an unknown or used code is not
+// let in.
+func (h *CommandHandler) handleIncomingClientCome(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	percent := h.Club.Discounts.MembershipPercent(manager.Client.Username)
+	if code := manager.PromoCode; code != "" {
+		promo, ok := h.Club.Discounts.FindPromoCode(code)
+		if !ok {
+			return append(events, errorEvent(manager, ErrPromoCodeUnknown))
+		}
+		if h.Visits.PromoCodeUsed(code) {
+			return append(events, errorEvent(manager, ErrPromoCodeUsed))
+		}
+
+		if promo.Percent > percent {
+			percent = promo.Percent
+		}
+	}
 
 	if err := h.Clients.Add(manager.Client); err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+		return append(events, errorEvent(manager, err))
 	}
-	return sb.String()
+
+	h.Tables.SetClientDiscount(manager.Client.Username, percent)
+	if manager.PromoCode != "" {
+		if err := h.Visits.UsePromoCode(manager.PromoCode); err != nil {
+			events = append(events, errorEvent(manager, err))
+		}
+	}
+	return events
 }
 
-func (h *CommandHandler) handleIncomingClientTookTheTable(manager *club.Manager) string {
-	var sb strings.Builder
-	sb.WriteString(manager.String())
+func (h *CommandHandler) handleIncomingClientTookTheTable(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
 
 	c, err := h.Clients.Get(manager.Client.Username)
 	if err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
-		return sb.String()
+		return append(events, errorEvent(manager, err))
 	}
 
 	if err := h.Clients.UpdateStatus(c.Username, manager.ID); err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+		events = append(events, errorEvent(manager, err))
 	}
+	h.Queue.Remove(c.Username)
 
 	if currentID, ok := h.Tables.Exists(c.Username); ok {
-		h.Tables.TakeDownTable(c.Username)
-		err = h.Tables.UpdateRevenue(currentID, h.Club.Price, manager.Time)
+		if _, err := h.Tables.TakeDownTable(c.Username); err != nil {
+			return append(events, errorEvent(manager, err))
+		}
+		session, err := h.Tables.UpdateRevenue(currentID, h.Club.Price, manager.Time)
 		if err != nil {
-			return err.Error()
+			return append(events, errorEvent(manager, err))
+		}
+		if err := h.endSession(session, c.Username, table.SessionMoved); err != nil {
+			events = append(events, errorEvent(manager, err))
 		}
 	}
 
 	if err := h.Tables.TakeUpTable(c.Username, manager.TableID, manager.Time); err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+		return append(events, h.seatElsewhere(manager, err)...)
 	}
 
-	return sb.String()
+	return events
 }
 
-func (h *CommandHandler) handleIncomingClientIsWaiting(manager *club.Manager) string {
-	var sb strings.Builder
-	sb.WriteString(manager.String())
+// seatElsewhere handles the client refused the table. A client who
+// reserved it while someone else still sits at it gets a free table of the
+// same category instead, if there is one.
+func (h *CommandHandler) seatElsewhere(manager *club.Manager, err error) []report.Event {
+	if !errors.Is(err, table.ErrPlaceIsBusy) {
+		return []report.Event{errorEvent(manager, err)}
+	}
+
+	username := manager.Client.Username
+	tableID, moveErr := h.Tables.MoveReservation(username, manager.TableID, manager.Time)
+	if moveErr != nil {
+		return []report.Event{errorEvent(manager, err)}
+	}
+
+	if err := h.Tables.TakeUpTable(username, tableID, manager.Time); err != nil {
+		return []report.Event{errorEvent(manager, err)}
+	}
+	return []report.Event{report.NewOutgoingEvent(manager.Time, OutgoingReservationMoved, username, tableID)}
+}
+
+func (h *CommandHandler) handleIncomingClientIsWaiting(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
 
 	c, err := h.Clients.Get(manager.Client.Username)
 	if err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
-		return sb.String()
+		return append(events, errorEvent(manager, err))
 	}
 
-	if err := h.Clients.UpdateStatus(c.Username, manager.ID); err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+	if err := h.Clients.MarkWaiting(c.Username, manager.Time); err != nil {
+		events = append(events, errorEvent(manager, err))
 	}
+	h.Queue.Push(h.queueEntry(c.Username, manager.Time))
 
 	if h.Tables.CountEmptyTables() != 0 {
-		sb.WriteString(manager.ErrorString(ErrClientIsWaiting, OutgoingClientError))
+		events = append(events, errorEvent(manager, ErrClientIsWaiting))
 	}
 
-	if len(h.Clients.Queue()) > h.Club.MaxTables {
+	if h.Queue.Len() > h.Club.QueueCapacity() {
+		h.Queue.Remove(c.Username)
+		h.endOffers(c.Username)
 		if err := h.Clients.Remove(c.Username); err != nil {
-			sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+			events = append(events, errorEvent(manager, err))
 		}
-		sb.WriteString(manager.OutgoingString(OutgoingClientAfterClose, c.Username, manager.TableID))
+		events = append(events, report.NewOutgoingEvent(manager.Time, OutgoingClientAfterClose, c.Username, 0))
 	}
 
-	return sb.String()
+	return events
 }
 
-func (h *CommandHandler) handleIncomingClientLeft(manager *club.Manager) string {
-	var sb strings.Builder
-	sb.WriteString(manager.String())
+func (h *CommandHandler) queueEntry(username string, enqueuedAt time.Time) *queue.Entry {
+	entry := &queue.Entry{
+		Username:   username,
+		EnqueuedAt: enqueuedAt,
+	}
+
+	if h.Club.Queue != nil {
+		entry.VIP = h.Club.Queue.VIPClients[username]
+		entry.ExpectedDuration = h.Club.Queue.ExpectedSessions[username]
+	}
+
+	return entry
+}
+
+func (h *CommandHandler) handleIncomingClientLeft(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
 
 	c, err := h.Clients.Get(manager.Client.Username)
 	if err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
-		return sb.String()
+		return append(events, errorEvent(manager, err))
 	}
 
-	tableID := h.Tables.TakeDownTable(c.Username)
+	tableID, err := h.Tables.TakeDownTable(c.Username)
+	if err != nil {
+		events = append(events, errorEvent(manager, err))
+	}
+	h.Queue.Remove(c.Username)
+	h.endOffers(c.Username)
 
 	if err := h.Clients.Remove(c.Username); err != nil {
-		sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+		events = append(events, errorEvent(manager, err))
 	}
 
 	if tableID != 0 {
-		err = h.Tables.UpdateRevenue(tableID, h.Club.Price, manager.Time)
+		session, err := h.Tables.UpdateRevenue(tableID, h.Club.Price, manager.Time)
 		if err != nil {
-			return err.Error()
+			return append(events, errorEvent(manager, err))
+		}
+		if err := h.endSession(session, c.Username, table.SessionLeft); err != nil {
+			events = append(events, errorEvent(manager, err))
 		}
 	}
 
-	if len(h.Clients.Queue()) != 0 && tableID != 0 {
-		usernameFirstQueue := h.Clients.Queue()[0].Username
+	if tableID == 0 {
+		return events
+	}
+
+	return append(events, h.seatFromQueue(tableID, manager.Time)...)
+}
 
-		if err := h.Tables.TakeUpTable(usernameFirstQueue, tableID, manager.Time); err != nil {
-			sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+// seatFromQueue gives the table freed at t to the first client in the
+// queue who may take it. A table reserved for someone else is only given to
+// the client who reserved it; everyone else keeps their place in the queue.
+func (h *CommandHandler) seatFromQueue(tableID int, t time.Time) []report.Event {
+	for _, next := range h.Queue.Entries() {
+		if err := h.Tables.TakeUpTable(next.Username, tableID, t); err != nil {
+			continue
 		}
+		h.Queue.Remove(next.Username)
 
-		if err := h.Clients.UpdateStatus(usernameFirstQueue, IncomingClientTookTheTable); err != nil {
-			sb.WriteString(manager.ErrorString(err, OutgoingClientError))
+		var events []report.Event
+		if err := h.Visits.SetQueuedIn(next.Username, true); err != nil {
+			events = append(events, report.NewErrorEvent(t, OutgoingClientError, err))
+		}
+		if err := h.Clients.UpdateStatus(next.Username, club.IncomingClientTookTheTable); err != nil {
+			events = append(events, report.NewErrorEvent(t, OutgoingClientError, err))
 		}
+		return append(events, report.NewOutgoingEvent(t, OutgoingClientTokeTheTableAfterWaiting, next.Username, tableID))
+	}
 
-		sb.WriteString(manager.OutgoingString(OutgoingClientTokeTheTableAfterWaiting, usernameFirstQueue, tableID))
+	return nil
+}
+
+// handleIncomingClientBooked reserves a table, or a table of a category,
+// for the client. The client does not have to be in the club.
+func (h *CommandHandler) handleIncomingClientBooked(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	reservation := *manager.Reservation
+	reservation.Client = manager.Client.Username
+	reservation.Grace = h.Club.ReservationGrace
+
+	if reservation.Start.Before(manager.Time) {
+		return append(events, errorEvent(manager, ErrReservationInPast))
 	}
 
-	return sb.String()
+	if err := h.Tables.Reserve(&reservation); err != nil {
+		events = append(events, errorEvent(manager, err))
+	}
+	return events
 }
 
-func (h *CommandHandler) calculateRevenue() string {
-	var sb strings.Builder
+func (h *CommandHandler) handleIncomingClientCancelled(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	if _, err := h.Tables.CancelReservation(manager.Client.Username); err != nil {
+		events = append(events, errorEvent(manager, err))
+	}
+	return events
+}
+
+// handleIncomingClientToppedUp adds to the prepaid balance of the client,
+// opening an account on the first top-up. The client does not have to be
+// in the club.
+func (h *CommandHandler) handleIncomingClientToppedUp(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	if _, err := h.Accounts.TopUp(manager.Client.Username, manager.Amount); err != nil {
+		return append(events, errorEvent(manager, err))
+	}
+	h.ledgerEntry(manager.Client.Username).TopUps += manager.Amount
+
+	// The session may already cost more than the new balance covers.
+	return append(events, h.endPrepaidSessions(manager.Time)...)
+}
+
+// handleIncomingClientBoughtPackage gives the client in the club a time
+// package of the club for the rest of the visit.
+func (h *CommandHandler) handleIncomingClientBoughtPackage(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	c, err := h.Clients.Get(manager.Client.Username)
+	if err != nil {
+		return append(events, errorEvent(manager, err))
+	}
+
+	p, ok := club.FindPackage(h.Club.Packages, manager.Package)
+	if !ok {
+		return append(events, errorEvent(manager, ErrPackageUnknown))
+	}
+
+	if err := h.Tables.SetClientPackage(c.Username, &p); err != nil {
+		events = append(events, errorEvent(manager, err))
+	}
+	return events
+}
+
+// handleIncomingClientPaused stops charging the client who stepped away;
+// the table stays taken.
+func (h *CommandHandler) handleIncomingClientPaused(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	c, err := h.Clients.Get(manager.Client.Username)
+	if err != nil {
+		return append(events, errorEvent(manager, err))
+	}
+
+	if _, err := h.Tables.Pause(c.Username, manager.Time); err != nil {
+		events = append(events, errorEvent(manager, err))
+	}
+	return events
+}
+
+func (h *CommandHandler) handleIncomingClientResumed(manager *club.Manager) []report.Event {
+	events := []report.Event{report.NewIncomingEvent(manager)}
+
+	c, err := h.Clients.Get(manager.Client.Username)
+	if err != nil {
+		return append(events, errorEvent(manager, err))
+	}
+
+	if _, err := h.Tables.Resume(c.Username, manager.Time); err != nil {
+		events = append(events, errorEvent(manager, err))
+	}
+	return events
+}
+
+// endLongPauses frees the tables of the clients who have been away for
+// longer than the club allows by t. The session ends when the pause ran
+// out, the client stays in the club and the table goes to the queue.
+func (h *CommandHandler) endLongPauses(t time.Time) []report.Event {
+	if h.Club.MaxPause == 0 {
+		return nil
+	}
+
+	var events []report.Event
+	for _, tableID := range h.Tables.PausedLonger(h.Club.MaxPause, t) {
+		tbl := h.Tables.GetAll()[tableID]
+		clientName := tbl.ClientName
+		end := tbl.Pauses[len(tbl.Pauses)-1].Start.Add(h.Club.MaxPause)
+
+		if _, err := h.Tables.TakeDownTable(clientName); err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+		}
+		session, err := h.Tables.UpdateRevenue(tableID, h.Club.Price, end)
+		if err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+			continue
+		}
+		if err := h.endSession(session, clientName, table.SessionPaused); err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+		}
+		if err := h.Clients.UpdateStatus(clientName, club.IncomingClientCome); err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+		}
+
+		events = append(events, report.NewOutgoingEvent(end, OutgoingPauseExpired, clientName, tableID))
+		events = append(events, h.seatFromQueue(tableID, end)...)
+	}
+
+	return events
+}
+
+// endOffers takes the discount and the package of the visit away from the
+// client leaving the club.
+func (h *CommandHandler) endOffers(clientName string) {
+	h.Tables.SetClientDiscount(clientName, 0)
+	_ = h.Tables.SetClientPackage(clientName, nil)
+}
+
+// expire processes what happened in the club since the previous event: the
+// reservations nobody came for, the prepaid sessions the balance no longer
+// covers and the pauses that went on for too long, in time order.
+func (h *CommandHandler) expire(t time.Time) []report.Event {
+	events := append(h.releaseReservations(t), h.endPrepaidSessions(t)...)
+	events = append(events, h.endLongPauses(t)...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	h.expired = t
+	return events
+}
+
+// endPrepaidSessions frees the tables of the clients whose balance ran out
+// by t. The session ends at the last minute the balance covers, but not
+// before the events already processed, the client stays in the club and the
+// table goes to the queue.
+func (h *CommandHandler) endPrepaidSessions(t time.Time) []report.Event {
+	var events []report.Event
+
+	tables := h.Tables.GetAll()
+	for tableID := 1; tableID <= h.Club.MaxTables; tableID++ {
+		tbl, ok := tables[tableID]
+		if !ok || tbl.ClientName == "" {
+			continue
+		}
+
+		clientName := tbl.ClientName
+		balance, ok := h.Accounts.Balance(clientName)
+		if !ok {
+			continue
+		}
+
+		end, ok := h.Tables.RunsOut(tableID, h.Club.Price, balance, h.expired, t)
+		if !ok {
+			continue
+		}
+
+		if _, err := h.Tables.TakeDownTable(clientName); err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+		}
+		session, err := h.Tables.UpdateRevenue(tableID, h.Club.Price, end)
+		if err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+			continue
+		}
+		if err := h.endSession(session, clientName, table.SessionBalance); err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+		}
+		if err := h.Clients.UpdateStatus(clientName, club.IncomingClientCome); err != nil {
+			events = append(events, report.NewErrorEvent(end, OutgoingClientError, err))
+		}
+
+		events = append(events, report.NewOutgoingEvent(end, OutgoingBalanceRunOut, clientName, tableID))
+		events = append(events, h.seatFromQueue(tableID, end)...)
+	}
+
+	return events
+}
+
+// releaseReservations gives up the tables held for the clients who did not
+// come by the deadline of their reservation. A released table nobody sits
+// at goes to the queue.
+func (h *CommandHandler) releaseReservations(t time.Time) []report.Event {
+	var events []report.Event
+	for _, r := range h.Tables.ReleaseExpired(t) {
+		events = append(events, report.NewOutgoingEvent(r.Deadline(), OutgoingReservationReleased, r.Client, r.TableID))
+
+		if tbl, ok := h.Tables.GetAll()[r.TableID]; !ok || tbl.ClientName == "" {
+			events = append(events, h.seatFromQueue(r.TableID, r.Deadline())...)
+		}
+	}
+	return events
+}
+
+// endSession records the session the client has finished at the table in
+// the working day and charges it to the prepaid balance, if the client has
+// one.
+func (h *CommandHandler) endSession(session table.Session, clientName string, reason table.EndReason) error {
+	session.Client = clientName
+	session.Reason = reason
+	session.QueuedIn = h.Visits.QueuedIn(clientName)
+
+	h.day.Sessions = append(h.day.Sessions, session)
+
+	var err error
+	if session.QueuedIn {
+		err = h.Visits.SetQueuedIn(clientName, false)
+	}
+
+	if _, chargeErr := h.Accounts.Charge(clientName, session.Amount); !errors.Is(chargeErr, client.ErrAccountNotFound) {
+		h.ledgerEntry(clientName).Charges += session.Amount
+		if chargeErr != nil {
+			err = chargeErr
+		}
+	}
+	return err
+}
+
+func (h *CommandHandler) ledgerEntry(clientName string) *report.LedgerEntry {
+	entry, ok := h.ledger[clientName]
+	if !ok {
+		entry = &report.LedgerEntry{Client: clientName}
+		h.ledger[clientName] = entry
+	}
+	return entry
+}
+
+// ledgerEntries lists every prepaid account with the top-ups and charges
+// of the working day in progress, by client name.
+func (h *CommandHandler) ledgerEntries() []report.LedgerEntry {
+	accounts := h.Accounts.GetAll()
+
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var entries []report.LedgerEntry
+	for _, name := range names {
+		entry := report.LedgerEntry{Client: name}
+		if activity, ok := h.ledger[name]; ok {
+			entry = *activity
+		}
+		entry.Closing = accounts[name].Balance
+		entry.Opening = entry.Closing - entry.TopUps + entry.Charges
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// tableTotals copies the revenue and occupied time the tables have
+// collected so far.
+func (h *CommandHandler) tableTotals() map[int]table.Table {
+	totals := make(map[int]table.Table)
+
+	for tableID, t := range h.Tables.GetAll() {
+		bands := make(map[string]int, len(t.BandRevenue))
+		for band, revenue := range t.BandRevenue {
+			bands[band] = revenue
+		}
+		totals[tableID] = table.Table{Revenue: t.Revenue, Discount: t.Discount, AllTime: t.AllTime, BandRevenue: bands}
+	}
+
+	return totals
+}
+
+// calculateRevenue summarises every table since the given totals were
+// taken; nil totals summarise the whole period.
+func (h *CommandHandler) calculateRevenue(since map[int]table.Table) []report.TableSummary {
+	summaries := make([]report.TableSummary, 0, h.Club.MaxTables)
 	tables := h.Tables.GetAll()
 
 	for tableID := 1; tableID <= h.Club.MaxTables; tableID++ {
+		category := club.FindCategory(h.Club.Categories, tableID).Name
 		if t, ok := tables[tableID]; ok {
-			sb.WriteString(fmt.Sprintf("%d %d %s\n", t.TableID, t.Revenue, t.AllTimeFormatted()))
+			prev := since[tableID]
+			summaries = append(summaries, report.TableSummary{
+				TableID:  t.TableID,
+				Category: category,
+				Revenue:  t.Revenue - prev.Revenue,
+				Discount: t.Discount - prev.Discount,
+				Occupied: t.AllTime - prev.AllTime,
+				Bands:    h.bandRevenue(t, prev),
+			})
 		} else {
-			sb.WriteString(fmt.Sprintf("%d %d %s\n", tableID, 0, "00:00"))
+			summaries = append(summaries, report.TableSummary{TableID: tableID, Category: category})
 		}
 	}
 
-	return sb.String()
+	return summaries
 }
 
-func (h *CommandHandler) checkLastClient() string {
-	var sb strings.Builder
+// bandRevenue splits the table revenue by tariff band, in the order the
+// bands are configured. Clubs without tariffs have no split.
+func (h *CommandHandler) bandRevenue(t *table.Table, prev table.Table) []report.BandRevenue {
+	if len(h.Club.Tariffs) == 0 {
+		return nil
+	}
+
+	bands := []report.BandRevenue{{Name: table.BaseBand, Revenue: t.BandRevenue[table.BaseBand] - prev.BandRevenue[table.BaseBand]}}
+	for _, band := range h.Club.Tariffs {
+		bands = append(bands, report.BandRevenue{Name: band.Name, Revenue: t.BandRevenue[band.Name] - prev.BandRevenue[band.Name]})
+	}
+	return bands
+}
+
+// categorySummaries groups the table summaries by category, standard tables
+// first. Clubs without categories have no grouping.
+func (h *CommandHandler) categorySummaries(tables []report.TableSummary) []report.CategorySummary {
+	if len(h.Club.Categories) == 0 {
+		return nil
+	}
+
+	names := []string{club.StandardCategory}
+	for _, category := range h.Club.Categories {
+		names = append(names, category.Name)
+	}
+
+	var summaries []report.CategorySummary
+	for _, name := range names {
+		summary := report.CategorySummary{Name: name}
+		for _, t := range tables {
+			if t.Category == name {
+				summary.Tables++
+				summary.Revenue += t.Revenue
+				summary.Occupied += t.Occupied
+			}
+		}
+
+		if summary.Tables != 0 {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries
+}
+
+// clientSummaries follows every client through the events and sessions:
+// the visits, the time spent in the queue, being sent away, the time played
+// and the amount owed. An incoming event answered with an error other than
+// ICanWaitNoLonger! did not happen for the client.
+func (h *CommandHandler) clientSummaries(events []report.Event, sessions []table.Session) []report.ClientSummary {
+	summaries := make(map[string]*report.ClientSummary)
+	waitingSince := make(map[string]time.Time)
+
+	stopWaiting := func(name string, t time.Time) {
+		if since, ok := waitingSince[name]; ok {
+			summaries[name].Waited += t.Sub(since)
+			delete(waitingSince, name)
+		}
+	}
+
+	for i, e := range events {
+		if e.ID == club.IncomingClientCome && !e.Generated {
+			next := i + 1
+			if next < len(events) && events[next].Generated && events[next].Error != "" {
+				continue
+			}
+
+			if _, ok := summaries[e.Client]; !ok {
+				summaries[e.Client] = &report.ClientSummary{Client: e.Client}
+			}
+			summaries[e.Client].Visits++
+			continue
+		}
+
+		summary, ok := summaries[e.Client]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case e.ID == club.IncomingClientIsWaiting && !e.Generated:
+			next := i + 1
+			if next < len(events) && events[next].Generated && events[next].Error != "" && events[next].Error != ErrClientIsWaiting.Error() {
+				continue
+			}
+
+			if _, waiting := waitingSince[e.Client]; !waiting {
+				waitingSince[e.Client] = e.Time
+			}
+		case e.ID == club.IncomingClientTookTheTable, e.ID == club.IncomingClientLeft, e.ID == OutgoingClientTokeTheTableAfterWaiting:
+			stopWaiting(e.Client, e.Time)
+		case e.ID == OutgoingClientAfterClose:
+			stopWaiting(e.Client, e.Time)
+			summary.Ejected = true
+		}
+	}
+
+	for _, session := range sessions {
+		summary, ok := summaries[session.Client]
+		if !ok {
+			summary = &report.ClientSummary{Client: session.Client}
+			summaries[session.Client] = summary
+		}
+
+		summary.Played += session.Duration()
+		summary.Amount += session.Amount
+		if session.Reason == table.SessionMoved {
+			summary.TableChanges++
+		}
+	}
+
+	names := make([]string, 0, len(summaries))
+	for name := range summaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]report.ClientSummary, 0, len(names))
+	for _, name := range names {
+		result = append(result, *summaries[name])
+	}
+	return result
+}
+
+func (h *CommandHandler) checkLastClient(closeTime time.Time) []report.Event {
+	var events []report.Event
 	clients := h.Clients.GetAll()
 
 	var queueClientNames []string
@@ -199,31 +952,66 @@ func (h *CommandHandler) checkLastClient() string {
 	sort.Strings(queueClientNames)
 
 	for _, clientName := range queueClientNames {
-		sb.WriteString(fmt.Sprintf("%s %d %s\n", h.Club.WorkingTime.Close.Format(club.TimeFormat), OutgoingClientAfterClose, clientName))
+		events = append(events, report.NewOutgoingEvent(closeTime, OutgoingClientAfterClose, clientName, 0))
 
 		if currentID, ok := h.Tables.Exists(clientName); ok {
-			err := h.Tables.UpdateRevenue(currentID, h.Club.Price, h.Club.WorkingTime.Close)
+			session, err := h.Tables.UpdateRevenue(currentID, h.Club.Price, closeTime)
 			if err != nil {
-				return err.Error()
+				return append(events, report.NewErrorEvent(closeTime, OutgoingClientError, err))
+			}
+			if err := h.endSession(session, clientName, table.SessionClosed); err != nil {
+				events = append(events, report.NewErrorEvent(closeTime, OutgoingClientError, err))
 			}
 
-			h.Tables.TakeDownTable(clientName)
+			if _, err := h.Tables.TakeDownTable(clientName); err != nil {
+				events = append(events, report.NewErrorEvent(closeTime, OutgoingClientError, err))
+			}
 		}
+		h.Queue.Remove(clientName)
+		h.endOffers(clientName)
 
 		err := h.Clients.Remove(clientName)
 		if err != nil {
-			return err.Error()
+			return append(events, report.NewErrorEvent(closeTime, OutgoingClientError, err))
 		}
 	}
 
-	return sb.String()
+	return events
+}
+
+// RestoreQueue puts the clients recovered from a persistent repository in
+// the queue state back in the queue, in the order they joined it; clients
+// who joined in the same minute go by name. It returns how many there were.
+func (h *CommandHandler) RestoreQueue() int {
+	var waiting []*client.Client
+	for _, c := range h.Clients.GetAll() {
+		if c.State == client.QueueState {
+			waiting = append(waiting, c)
+		}
+	}
+
+	sort.Slice(waiting, func(i, j int) bool {
+		if !waiting[i].EnqueuedAt.Equal(waiting[j].EnqueuedAt) {
+			return waiting[i].EnqueuedAt.Before(waiting[j].EnqueuedAt)
+		}
+		return waiting[i].Username < waiting[j].Username
+	})
+
+	for _, c := range waiting {
+		h.Queue.Push(h.queueEntry(c.Username, c.EnqueuedAt))
+	}
+	return len(waiting)
 }
 
-func NewCommandHandler(club *club.Club, managers []*club.Manager, clients client.ClientRepository, tables table.TableRepository) *CommandHandler {
+func NewCommandHandler(club *club.Club, managers []*club.Manager, clients client.ClientRepository, tables table.TableRepository, waiting queue.Queue) *CommandHandler {
 	return &CommandHandler{
 		Club:     club,
 		Managers: managers,
 		Clients:  clients,
 		Tables:   tables,
+		Queue:    waiting,
+		Accounts: client.NewAccountMemoryRepo(),
+		Visits:   client.NewVisitMemoryRepo(),
+		ledger:   make(map[string]*report.LedgerEntry),
 	}
 }
@@ -0,0 +1,677 @@
+package handlers
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/apartapatia/computer_club_assistant/internal/myparser"
+	"github.com/apartapatia/computer_club_assistant/pkg/client"
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/queue"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+	"github.com/apartapatia/computer_club_assistant/pkg/table"
+)
+
+func newTestHandler(t *testing.T, input string) *CommandHandler {
+	t.Helper()
+
+	pars := myparser.NewFileParser(strings.NewReader(input))
+	clubInfo, err := pars.ReadClubInfo()
+	if err != nil {
+		t.Fatalf("ReadClubInfo: %v", err)
+	}
+
+	managers, err := pars.ReadManagerEvents(clubInfo)
+	if err != nil {
+		t.Fatalf("ReadManagerEvents: %v", err)
+	}
+
+	billing, err := table.NewBillingStrategy(clubInfo.Billing.Strategy, clubInfo.Billing.Block, clubInfo.Billing.Grace)
+	if err != nil {
+		t.Fatalf("NewBillingStrategy: %v", err)
+	}
+
+	tables := table.NewMemoryRepo(clubInfo.MaxTables, billing, table.NewTariff(clubInfo.Tariffs), table.NewDiscounts(clubInfo.Discounts), clubInfo.Categories)
+	return NewCommandHandler(clubInfo, managers, client.NewMemoryRepo(), tables, queue.NewMemoryQueue(nil))
+}
+
+func TestHandleEvent(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n09:10 1 client1\n09:10 2 client1 1\n")
+	for _, m := range h.Managers {
+		if out, err := h.HandleEvent(m); err != nil || len(out) != 0 {
+			t.Fatalf("HandleEvent(%s) = %v, %v", strings.TrimSpace(m.String()), out, err)
+		}
+	}
+
+	out, err := h.HandleEvent(club.NewManager(h.Managers[1].Time, club.IncomingClientCome, "client1", 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 1 || out[0].ID != OutgoingClientError || !out[0].Generated {
+		t.Errorf("expected a single error event, got %v", out)
+	}
+
+	if _, err := h.HandleEvent(club.NewManager(h.Managers[1].Time, 20, "client2", 0)); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("expected ErrUnknownEvent, got %v", err)
+	}
+
+	if _, err := h.HandleEvent(club.NewManager(h.Managers[0].Time.Add(-1), club.IncomingClientCome, "client2", 0)); !errors.Is(err, ErrEventOutOfOrder) {
+		t.Errorf("expected ErrEventOutOfOrder, got %v", err)
+	}
+
+	if _, err := h.HandleEvent(nil); !errors.Is(err, ErrManagerIsNil) {
+		t.Errorf("expected ErrManagerIsNil, got %v", err)
+	}
+
+	day := h.CloseDay()
+	if day == nil {
+		t.Fatal("expected the working day to be closed")
+	}
+
+	if len(day.Tables) != 1 || day.Tables[0].Revenue != 100 {
+		t.Errorf("expected table 1 to earn 100, got %+v", day.Tables)
+	}
+
+	last := day.Events[len(day.Events)-1]
+	if last.ID != OutgoingClientAfterClose || last.Client != "client1" {
+		t.Errorf("expected client1 to leave at close, got %v", last)
+	}
+
+	if h.CloseDay() != nil {
+		t.Error("expected no day to close twice")
+	}
+}
+
+func TestReportRejectedEvents(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n09:10 1 client1\n")
+
+	topUp := club.NewManager(h.Managers[0].Time, club.IncomingClientToppedUp, "client1", 0)
+	late := club.NewManager(h.Managers[0].Time.Add(-time.Minute), club.IncomingClientCome, "client2", 0)
+	h.Managers = append([]*club.Manager{topUp}, append(h.Managers, late)...)
+
+	var lines []string
+	for _, e := range h.Report().Days[0].Events {
+		lines = append(lines, e.String())
+	}
+
+	want := "09:10 7 client1,09:10 13 InvalidAmount,09:10 1 client1,09:09 1 client2,09:09 13 EventOutOfOrder,19:00 11 client1"
+	if got := strings.Join(lines, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestOvernightClub(t *testing.T) {
+	h := newTestHandler(t, "2\n20:00 06:00\n10\n"+
+		"20:10 1 anna\n20:15 2 anna 1\n23:40 1 boris\n23:45 2 boris 2\n"+
+		"01:20 4 anna\n02:00 1 clara\n02:05 2 clara 1\n05:30 4 boris\n")
+	r := h.Report()
+
+	if len(r.Days) != 1 {
+		t.Fatalf("expected a single working day across midnight, got %d", len(r.Days))
+	}
+	day := r.Days[0]
+
+	if !day.Close.Equal(day.Open.Add(10 * time.Hour)) {
+		t.Errorf("expected the day to close 10 hours after %v, got %v", day.Open, day.Close)
+	}
+
+	last := day.Events[len(day.Events)-1]
+	if last.ID != OutgoingClientAfterClose || last.Client != "clara" || !last.Time.Equal(day.Close) {
+		t.Errorf("expected clara sent away at 06:00, got %v", last)
+	}
+
+	expected := []table.Session{
+		{Client: "anna", TableID: 1, Billed: 6 * time.Hour, Amount: 60, Reason: table.SessionLeft},
+		{Client: "boris", TableID: 2, Billed: 6 * time.Hour, Amount: 60, Reason: table.SessionLeft},
+		{Client: "clara", TableID: 1, Billed: 4 * time.Hour, Amount: 40, Reason: table.SessionClosed},
+	}
+	if len(day.Sessions) != len(expected) {
+		t.Fatalf("expected %d sessions, got %+v", len(expected), day.Sessions)
+	}
+	for i, e := range expected {
+		s := day.Sessions[i]
+		if s.Client != e.Client || s.TableID != e.TableID || s.Billed != e.Billed || s.Amount != e.Amount || s.Reason != e.Reason {
+			t.Errorf("session %d: expected %+v, got %+v", i, e, s)
+		}
+	}
+
+	if day.Tables[0].Revenue != 100 || day.Tables[0].Occupied != 9*time.Hour || day.Tables[1].Revenue != 60 {
+		t.Errorf("unexpected tables %+v", day.Tables)
+	}
+}
+
+func TestDayRollover(t *testing.T) {
+	h := newTestHandler(t, "2\n09:00 19:00\n10\n"+
+		"2026-10-18 09:41 1 anna\n2026-10-18 09:45 2 anna 1\n2026-10-18 12:00 1 boris\n2026-10-18 12:00 2 boris 2\n2026-10-18 15:20 4 boris\n"+
+		"2026-10-19 10:00 1 clara\n2026-10-19 10:05 2 clara 2\n2026-10-19 13:00 4 clara\n")
+	r := h.Report()
+
+	if len(r.Days) != 2 {
+		t.Fatalf("expected 2 working days, got %d", len(r.Days))
+	}
+
+	first, second := r.Days[0], r.Days[1]
+	if first.Date.Format(club.DateFormat) != "2026-10-18" || second.Date.Format(club.DateFormat) != "2026-10-19" {
+		t.Errorf("unexpected dates %v and %v", first.Date, second.Date)
+	}
+
+	last := first.Events[len(first.Events)-1]
+	if last.ID != OutgoingClientAfterClose || last.Client != "anna" || !last.Time.Equal(first.Close) {
+		t.Errorf("expected anna sent away when the first day closed, got %v", last)
+	}
+	if len(second.Events) != 3 {
+		t.Errorf("expected the second day to hold its own 3 events, got %v", second.Events)
+	}
+
+	if first.Tables[0].Revenue != 100 || first.Tables[1].Revenue != 40 {
+		t.Errorf("unexpected first day tables %+v", first.Tables)
+	}
+	if second.Tables[0].Revenue != 0 || second.Tables[1].Revenue != 30 {
+		t.Errorf("unexpected second day tables %+v", second.Tables)
+	}
+	if r.Tables[0].Revenue != 100 || r.Tables[1].Revenue != 70 || r.Tables[1].Occupied != 6*time.Hour+15*time.Minute {
+		t.Errorf("unexpected totals %+v", r.Tables)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	h := newTestHandler(t, "2\n09:00 19:00\n10\n09:00 1 client1\n09:00 2 client1 1\n09:30 2 client1 2\n09:40 1 client2\n09:40 2 client2 1\n09:50 1 client3\n09:50 3 client3\n10:40 4 client2\n")
+	r := h.Report()
+
+	expected := []table.Session{
+		{Client: "client1", TableID: 1, Billed: time.Hour, Amount: 10, Reason: table.SessionMoved},
+		{Client: "client2", TableID: 1, Billed: time.Hour, Amount: 10, Reason: table.SessionLeft},
+		{Client: "client1", TableID: 2, Billed: 10 * time.Hour, Amount: 100, Reason: table.SessionClosed},
+		{Client: "client3", TableID: 1, Billed: 9 * time.Hour, Amount: 90, QueuedIn: true, Reason: table.SessionClosed},
+	}
+
+	sessions := r.Days[0].Sessions
+	if len(sessions) != len(expected) {
+		t.Fatalf("expected %d sessions, got %d: %+v", len(expected), len(sessions), sessions)
+	}
+
+	for i, e := range expected {
+		s := sessions[i]
+		if s.Client != e.Client || s.TableID != e.TableID || s.Billed != e.Billed || s.Amount != e.Amount || s.QueuedIn != e.QueuedIn || s.Reason != e.Reason {
+			t.Errorf("session %d: expected %+v, got %+v", i, e, s)
+		}
+	}
+}
+
+func TestClientSummaries(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n09:00 1 client1\n09:00 2 client1 1\n09:10 1 client2\n09:10 3 client2\n09:20 1 client3\n09:20 3 client3\n09:30 1 client1\n10:30 4 client1\n")
+	r := h.Report()
+
+	expected := []report.ClientSummary{
+		{Client: "client1", Visits: 1, Played: 90 * time.Minute, Amount: 20},
+		{Client: "client2", Visits: 1, Played: 510 * time.Minute, Amount: 90, Waited: 80 * time.Minute, Ejected: true},
+		{Client: "client3", Visits: 1, Ejected: true},
+	}
+
+	if len(r.Clients) != len(expected) {
+		t.Fatalf("expected %d clients, got %+v", len(expected), r.Clients)
+	}
+
+	for i, e := range expected {
+		if r.Clients[i] != e {
+			t.Errorf("client %d: expected %+v, got %+v", i, e, r.Clients[i])
+		}
+	}
+}
+
+func TestReservations(t *testing.T) {
+	h := newTestHandler(t, "2\n09:00 19:00\n10\n"+
+		"09:00 5 alice 1 10:00 60\n"+
+		"09:05 5 bob 1 10:30 60\n"+
+		"09:06 5 carol 2 12:00 60\n"+
+		"09:10 1 dave\n"+
+		"10:05 2 dave 1\n"+
+		"10:10 1 alice\n"+
+		"10:11 2 alice 1\n"+
+		"11:00 6 carol\n"+
+		"11:01 6 carol\n"+
+		"11:02 5 erin 2 11:30 60\n"+
+		"12:00 1 frank\n")
+
+	var errs, released []string
+	for _, e := range h.Report().Days[0].Events {
+		switch e.ID {
+		case OutgoingClientError:
+			errs = append(errs, e.Time.Format(club.TimeFormat)+" "+e.Error)
+		case OutgoingReservationReleased:
+			released = append(released, e.Time.Format(club.TimeFormat)+" "+e.Client)
+		}
+	}
+
+	want := []string{"09:05 ReservationConflict", "10:05 TableReserved", "11:01 ReservationUnknown"}
+	if strings.Join(errs, ",") != strings.Join(want, ",") {
+		t.Errorf("expected errors %v, got %v", want, errs)
+	}
+	if len(released) != 1 || released[0] != "11:45 erin" {
+		t.Errorf("expected erin's reservation released at 11:45, got %v", released)
+	}
+}
+
+func TestQueueSkipsReservedTable(t *testing.T) {
+	tests := []struct {
+		name   string
+		events string
+		seated string
+	}{
+		{
+			name: "client who reserved waits in the queue",
+			events: "09:30 1 client3\n" +
+				"09:31 3 client3\n" +
+				"10:00 4 client1\n" +
+				"11:00 4 client3\n",
+			seated: "10:00 12 client3 1,11:00 12 client2 1",
+		},
+		{
+			name: "client who reserved does not come",
+			events: "10:00 4 client1\n" +
+				"10:30 1 client5\n",
+			seated: "10:15 12 client2 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, "2\n09:00 19:00\n10\n"+
+				"09:00 5 client3 1 10:00 60\n"+
+				"09:10 1 client1\n"+
+				"09:10 2 client1 1\n"+
+				"09:10 1 client4\n"+
+				"09:10 2 client4 2\n"+
+				"09:20 1 client2\n"+
+				"09:21 3 client2\n"+
+				tt.events)
+
+			var seated, errs []string
+			for _, e := range h.Report().Days[0].Events {
+				switch e.ID {
+				case OutgoingClientTokeTheTableAfterWaiting:
+					seated = append(seated, e.String())
+				case OutgoingClientError:
+					errs = append(errs, e.Error)
+				}
+			}
+
+			if strings.Join(seated, ",") != tt.seated {
+				t.Errorf("expected %s, got %v", tt.seated, seated)
+			}
+			if len(errs) != 0 {
+				t.Errorf("expected no errors, got %v", errs)
+			}
+		})
+	}
+}
+
+func TestReservedTableTaken(t *testing.T) {
+	tests := []struct {
+		name   string
+		events string
+		want   []string
+	}{
+		{
+			name:   "client who reserved moves to a free table",
+			events: "",
+			want:   []string{"10:05 17 client3 2"},
+		},
+		{
+			name:   "client who reserved keeps the booking",
+			events: "09:50 1 client4\n09:50 2 client4 2\n",
+			want:   []string{"10:05 13 PlaceIsBusy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler(t, "2\n09:00 19:00\n10\n"+
+				"09:00 5 client3 1 10:00 60\n"+
+				"09:50 1 client1\n"+
+				"09:50 2 client1 1\n"+
+				tt.events+
+				"10:05 1 client3\n"+
+				"10:05 2 client3 1\n"+
+				"10:30 1 client5\n")
+
+			var got []string
+			for _, e := range h.Report().Days[0].Events {
+				if e.Generated && e.ID != OutgoingClientAfterClose {
+					got = append(got, e.String())
+				}
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRestoreQueue(t *testing.T) {
+	clock := func(value string) time.Time {
+		t.Helper()
+
+		v, err := time.Parse(club.TimeFormat, value)
+		if err != nil {
+			t.Fatalf("time.Parse(%s): %v", value, err)
+		}
+		return v
+	}
+
+	path := filepath.Join(t.TempDir(), "club.db")
+	clubInfo := club.NewClub(club.NewWorkingTime(clock("09:00"), clock("19:00")), 10, 1)
+
+	open := func() (*CommandHandler, *bolt.DB) {
+		db, err := bolt.Open(path, 0600, nil)
+		if err != nil {
+			t.Fatalf("failed to open store: %v", err)
+		}
+
+		clients, err := client.NewBoltRepo(db)
+		if err != nil {
+			t.Fatalf("client.NewBoltRepo: %v", err)
+		}
+		tables, err := table.NewBoltRepo(db, 1, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("table.NewBoltRepo: %v", err)
+		}
+		return NewCommandHandler(clubInfo, nil, clients, tables, queue.NewMemoryQueue(nil)), db
+	}
+
+	h, db := open()
+	for _, m := range []*club.Manager{
+		club.NewManager(clock("09:00"), club.IncomingClientCome, "client1", 0),
+		club.NewManager(clock("09:00"), club.IncomingClientTookTheTable, "client1", 1),
+		club.NewManager(clock("09:10"), club.IncomingClientCome, "client2", 0),
+		club.NewManager(clock("09:11"), club.IncomingClientIsWaiting, "client2", 0),
+	} {
+		if _, err := h.HandleEvent(m); err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+	db.Close()
+
+	h, db = open()
+	defer db.Close()
+
+	if waiting := h.RestoreQueue(); waiting != 1 {
+		t.Fatalf("expected one client back in the queue, got %d", waiting)
+	}
+
+	outgoing, err := h.HandleEvent(club.NewManager(clock("10:00"), club.IncomingClientLeft, "client1", 0))
+	if err != nil {
+		t.Fatalf("HandleEvent: %v", err)
+	}
+	if len(outgoing) != 1 || outgoing[0].String() != "10:00 12 client2 1" {
+		t.Errorf("expected client2 seated from the restored queue, got %v", outgoing)
+	}
+}
+
+func TestRestartFromStore(t *testing.T) {
+	clock := func(value string) time.Time {
+		t.Helper()
+
+		v, err := time.Parse(club.TimeFormat, value)
+		if err != nil {
+			t.Fatalf("time.Parse(%s): %v", value, err)
+		}
+		return v
+	}
+
+	path := filepath.Join(t.TempDir(), "club.db")
+	clubInfo := club.NewClub(club.NewWorkingTime(clock("09:00"), clock("19:00")), 10, 1)
+	clubInfo.Discounts = &club.Discounts{PromoCodes: []club.PromoCode{{Code: "welcome", Percent: 50}}}
+
+	open := func() (*CommandHandler, *bolt.DB) {
+		db, err := bolt.Open(path, 0600, nil)
+		if err != nil {
+			t.Fatalf("failed to open store: %v", err)
+		}
+
+		clients, err := client.NewBoltRepo(db)
+		if err != nil {
+			t.Fatalf("client.NewBoltRepo: %v", err)
+		}
+		tables, err := table.NewBoltRepo(db, 1, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("table.NewBoltRepo: %v", err)
+		}
+		accounts, err := client.NewAccountBoltRepo(db)
+		if err != nil {
+			t.Fatalf("client.NewAccountBoltRepo: %v", err)
+		}
+		visits, err := client.NewVisitBoltRepo(db)
+		if err != nil {
+			t.Fatalf("client.NewVisitBoltRepo: %v", err)
+		}
+
+		h := NewCommandHandler(clubInfo, nil, clients, tables, queue.NewMemoryQueue(nil))
+		h.Accounts = accounts
+		h.Visits = visits
+		return h, db
+	}
+
+	h, db := open()
+	if h.Visits.Initialised() {
+		t.Fatal("expected a new store not to be initialised")
+	}
+
+	welcome := club.NewManager(clock("09:05"), club.IncomingClientCome, "boris", 0)
+	welcome.PromoCode = "welcome"
+	topUp := club.NewManager(clock("09:00"), club.IncomingClientToppedUp, "anna", 0)
+	topUp.Amount = 50
+
+	for _, m := range []*club.Manager{
+		topUp,
+		welcome,
+		club.NewManager(clock("09:05"), club.IncomingClientTookTheTable, "boris", 1),
+		club.NewManager(clock("09:10"), club.IncomingClientCome, "clara", 0),
+		club.NewManager(clock("09:10"), club.IncomingClientIsWaiting, "clara", 0),
+		club.NewManager(clock("10:00"), club.IncomingClientLeft, "boris", 0),
+	} {
+		if _, err := h.HandleEvent(m); err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+	if err := h.Visits.MarkInitialised(); err != nil {
+		t.Fatalf("MarkInitialised: %v", err)
+	}
+	db.Close()
+
+	h, db = open()
+	defer db.Close()
+
+	if !h.Visits.Initialised() {
+		t.Fatal("expected the store to be initialised after a restart")
+	}
+	if balance, _ := h.Accounts.Balance("anna"); balance != 50 {
+		t.Errorf("expected the balance of anna kept at 50, got %d", balance)
+	}
+
+	again := club.NewManager(clock("10:30"), club.IncomingClientCome, "dima", 0)
+	again.PromoCode = "welcome"
+	outgoing, err := h.HandleEvent(again)
+	if err != nil {
+		t.Fatalf("HandleEvent: %v", err)
+	}
+	if len(outgoing) != 1 || outgoing[0].Error != ErrPromoCodeUsed.Error() {
+		t.Errorf("expected the promo code used before the restart refused, got %v", outgoing)
+	}
+
+	if _, err := h.HandleEvent(club.NewManager(clock("11:00"), club.IncomingClientLeft, "clara", 0)); err != nil {
+		t.Fatalf("HandleEvent: %v", err)
+	}
+	day := h.CloseDay()
+	if len(day.Sessions) != 1 || day.Sessions[0].Client != "clara" || !day.Sessions[0].QueuedIn {
+		t.Errorf("expected clara to stay seated from the queue across the restart, got %+v", day.Sessions)
+	}
+}
+
+func TestPrepaidBalance(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n"+
+		"09:00 7 client1 25\n"+
+		"09:10 1 client1\n"+
+		"09:10 2 client1 1\n"+
+		"09:30 1 client2\n"+
+		"09:31 3 client2\n"+
+		"12:00 7 client1 15\n")
+	r := h.Report()
+	day := r.Days[0]
+
+	var runOut []string
+	for _, e := range day.Events {
+		if e.Generated && (e.ID == OutgoingBalanceRunOut || e.ID == OutgoingClientTokeTheTableAfterWaiting) {
+			runOut = append(runOut, e.String())
+		}
+	}
+	if strings.Join(runOut, ",") != "11:10 15 client1 1,11:10 12 client2 1" {
+		t.Errorf("expected client1 to run out of balance at 11:10, got %v", runOut)
+	}
+
+	if s := day.Sessions[0]; s.Client != "client1" || s.Amount != 20 || s.Reason != table.SessionBalance {
+		t.Errorf("unexpected session %+v", s)
+	}
+
+	want := report.LedgerEntry{Client: "client1", Opening: 0, TopUps: 40, Charges: 20, Closing: 20}
+	if len(day.Ledger) != 1 || day.Ledger[0] != want {
+		t.Errorf("expected ledger %+v, got %+v", want, day.Ledger)
+	}
+}
+
+func TestTopUpMidSession(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n"+
+		"09:00 1 anna\n"+
+		"09:00 2 anna 1\n"+
+		"11:00 1 boris\n"+
+		"11:00 3 boris\n"+
+		"12:00 7 anna 10\n"+
+		"12:30 7 boris 100\n")
+	day := h.Report().Days[0]
+
+	var lines []string
+	for _, e := range day.Events {
+		lines = append(lines, e.String())
+	}
+	want := "09:00 1 anna,09:00 2 anna 1,11:00 1 boris,11:00 3 boris,12:00 7 anna 10,12:00 15 anna 1,12:00 12 boris 1,12:30 7 boris 100,19:00 11 anna,19:00 11 boris"
+	if got := strings.Join(lines, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if s := day.Sessions[0]; s.Client != "anna" || !s.End.Equal(day.Events[5].Time) || s.Amount != 30 || s.Reason != table.SessionBalance {
+		t.Errorf("expected anna to pay for three hours, got %+v", s)
+	}
+	if len(day.Sessions) != 2 || day.Sessions[1].Client != "boris" || day.Sessions[1].Amount != 70 {
+		t.Errorf("expected boris to play from 12:00, got %+v", day.Sessions)
+	}
+}
+
+func TestPromoCodes(t *testing.T) {
+	h := newTestHandler(t, "2\n09:00 19:00\n10\n")
+	h.Club.Discounts = &club.Discounts{PromoCodes: []club.PromoCode{{Code: "welcome", Percent: 50}}}
+
+	managers, err := myparser.NewFileParser(strings.NewReader("09:00 1 client1 welcome\n" +
+		"09:00 2 client1 1\n" +
+		"09:30 1 client2 welcome\n" +
+		"09:40 1 client3 unknown\n" +
+		"11:00 4 client1\n")).ReadManagerEvents(h.Club)
+	if err != nil {
+		t.Fatalf("ReadManagerEvents: %v", err)
+	}
+	h.Managers = managers
+
+	r := h.Report()
+
+	var errs []string
+	for _, e := range r.Days[0].Events {
+		if e.ID == OutgoingClientError {
+			errs = append(errs, e.Error)
+		}
+	}
+	if strings.Join(errs, ",") != "PromoCodeUsed,PromoCodeUnknown" {
+		t.Errorf("expected the second use and the unknown code refused, got %v", errs)
+	}
+
+	if s := r.Tables[0]; s.Revenue != 10 || s.Discount != 10 || s.Gross() != 20 {
+		t.Errorf("expected half off two hours, got %+v", s)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	h := newTestHandler(t, "2\n09:00 19:00\n10\n"+
+		"09:00 1 anna\n"+
+		"09:00 2 anna 1\n"+
+		"09:10 8 anna night\n"+
+		"09:20 8 anna night\n"+
+		"09:30 8 anna weekend\n"+
+		"09:40 8 boris night\n")
+	from, _ := time.Parse(club.TimeFormat, "18:00")
+	to, _ := time.Parse(club.TimeFormat, "08:00")
+	h.Club.Packages = []club.TimePackage{{Name: "night", TimeWindow: club.TimeWindow{From: from, To: to}, Price: 30}}
+
+	r := h.Report()
+
+	var errs []string
+	for _, e := range r.Days[0].Events {
+		if e.ID == OutgoingClientError {
+			errs = append(errs, e.Time.Format(club.TimeFormat)+" "+e.Error)
+		}
+	}
+	want := "09:20 PackageAlreadyBought,09:30 PackageUnknown,09:40 ClientUnknown"
+	if got := strings.Join(errs, ","); got != want {
+		t.Errorf("expected errors %s, got %s", want, got)
+	}
+
+	sessions := r.Days[0].Sessions
+	if len(sessions) != 1 || sessions[0].Package != "night" || sessions[0].Amount != 120 {
+		t.Errorf("expected 9 hours at the table price and the package for the last hour, got %+v", sessions)
+	}
+}
+
+func TestPauses(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n"+
+		"09:00 1 client1\n"+
+		"09:00 2 client1 1\n"+
+		"09:30 1 client2\n"+
+		"09:31 3 client2\n"+
+		"10:00 9 client1\n"+
+		"10:05 9 client1\n"+
+		"10:20 10 client1\n"+
+		"10:21 10 client1\n"+
+		"11:20 9 client1\n"+
+		"12:00 1 client3\n")
+	r := h.Report()
+	day := r.Days[0]
+
+	var errs, freed []string
+	for _, e := range day.Events {
+		switch {
+		case e.ID == OutgoingClientError:
+			errs = append(errs, e.Time.Format(club.TimeFormat)+" "+e.Error)
+		case e.Generated && (e.ID == OutgoingPauseExpired || e.ID == OutgoingClientTokeTheTableAfterWaiting):
+			freed = append(freed, e.String())
+		}
+	}
+
+	want := []string{"10:05 AlreadyPaused", "10:21 NotPaused"}
+	if strings.Join(errs, ",") != strings.Join(want, ",") {
+		t.Errorf("expected errors %v, got %v", want, errs)
+	}
+	if strings.Join(freed, ",") != "11:50 16 client1 1,11:50 12 client2 1" {
+		t.Errorf("expected the table given to client2 at 11:50, got %v", freed)
+	}
+
+	s := day.Sessions[0]
+	if s.Client != "client1" || s.Reason != table.SessionPaused || s.Duration() != 2*time.Hour || s.Amount != 20 {
+		t.Errorf("expected two hours played without the pauses, got %+v", s)
+	}
+	if tbl := r.Tables[0]; tbl.Occupied != 9*time.Hour+10*time.Minute || tbl.Revenue != 100 {
+		t.Errorf("expected the pauses left out of the table totals, got %+v", tbl)
+	}
+}
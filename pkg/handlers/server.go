@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/internal/myparser"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+var (
+	ErrMethodNotAllowed  = errors.New("MethodNotAllowed")
+	ErrStreamUnsupported = errors.New("StreamUnsupported")
+)
+
+// Server exposes a live club over HTTP. Every request is served by the
+// CommandHandler, one at a time; the changes are streamed to subscribers.
+type Server struct {
+	handler *CommandHandler
+	now     func() time.Time
+	mux     *http.ServeMux
+	mu      sync.Mutex
+}
+
+type revenueState struct {
+	Tables     []report.TableSummary    `json:"tables"`
+	Categories []report.CategorySummary `json:"categories,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleEvents accepts an incoming event in the layout of the events array
+// of a JSON document and answers with the events the club generated.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, err := myparser.DecodeEvent(r.Body, s.handler.Club, s.now(), s.handler.Dated())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	outgoing, err := s.handler.HandleEvent(m)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if outgoing == nil {
+		outgoing = []OutgoingEvent{}
+	}
+	writeJSON(w, http.StatusOK, outgoing)
+}
+
+func (s *Server) handleTables(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.handler.snapshot(s.now()).Tables)
+}
+
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.handler.snapshot(s.now()).Queue)
+}
+
+func (s *Server) handleRevenue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables := s.handler.calculateRevenue(nil)
+	writeJSON(w, http.StatusOK, revenueState{
+		Tables:     tables,
+		Categories: s.handler.categorySummaries(tables),
+	})
+}
+
+// handleState answers who sat at the tables and waited in the queue at the
+// moment given by the at query parameter, as HH:MM or YYYY-MM-DD HH:MM.
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	at, err := ParseStateTime(r.URL.Query().Get("at"), s.handler.Club)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.handler.StateAt(at)
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrNoState)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// handleReport renders the days processed so far in the format given by
+// the format query parameter, JSON by default.
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	format := report.FormatJSON
+	if name := r.URL.Query().Get("format"); name != "" {
+		var err error
+		if format, err = report.ParseFormat(name); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", contentType(format))
+	if err := report.NewWriter(format).Write(w, s.handler.report()); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// handleClose ends the working day in progress, sending the remaining
+// clients away, and answers with the summary of the day.
+func (s *Server) handleClose(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := s.handler.CloseDay()
+	if day == nil {
+		writeJSON(w, http.StatusOK, revenueState{Tables: []report.TableSummary{}})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revenueState{Tables: day.Tables, Categories: day.Categories})
+}
+
+// handleStream sends every processed event and table change to the client
+// as Server-Sent Events until the client goes away.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, ErrStreamUnsupported)
+		return
+	}
+
+	updates, cancel := s.handler.Updates.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case u := <-updates:
+			data, err := json.Marshal(u)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", u.Kind, data)
+			flusher.Flush()
+		}
+	}
+}
+
+func contentType(format report.Format) string {
+	switch format {
+	case report.FormatJSON:
+		return "application/json"
+	case report.FormatCSV:
+		return "text/csv"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// NewServer serves the club from the state of the handler on. The events
+// loaded into the handler are left for the caller to process first.
+func NewServer(handler *CommandHandler) *Server {
+	if handler.Updates == nil {
+		handler.Updates = NewBroadcaster()
+	}
+
+	s := &Server{
+		handler: handler,
+		now:     time.Now,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/events", s.handleEvents)
+	s.mux.HandleFunc("/tables", s.handleTables)
+	s.mux.HandleFunc("/queue", s.handleQueue)
+	s.mux.HandleFunc("/revenue", s.handleRevenue)
+	s.mux.HandleFunc("/report", s.handleReport)
+	s.mux.HandleFunc("/close", s.handleClose)
+	s.mux.HandleFunc("/stream", s.handleStream)
+	s.mux.HandleFunc("/state", s.handleState)
+	return s
+}
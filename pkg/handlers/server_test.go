@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer serves the club of the input with the events of the input
+// already handled, at the fixed moment now.
+func newTestServer(t *testing.T, input string, now time.Time) (*Server, *CommandHandler) {
+	t.Helper()
+
+	h := newTestHandler(t, input)
+	for _, m := range h.Managers {
+		if _, err := h.HandleEvent(m); err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+	h.Managers = nil
+
+	s := NewServer(h)
+	s.now = func() time.Time { return now }
+	return s, h
+}
+
+func serve(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestPostEventToUndatedLog(t *testing.T) {
+	now := time.Date(2026, 10, 18, 10, 5, 0, 0, time.UTC)
+	s, h := newTestServer(t, "1\n09:00 19:00\n10\n09:10 1 client1\n", now)
+
+	if rec := serve(s, http.MethodPost, "/events", `{"id": 2, "client": "client1", "table": 1}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	r := h.report()
+	if len(r.Days) != 1 {
+		t.Fatalf("expected the event to join the loaded day, got %d days", len(r.Days))
+	}
+
+	events := r.Days[0].Events
+	if last := events[len(events)-1]; last.String() != "10:05 2 client1 1" {
+		t.Errorf("expected the event stamped at 10:05, got %s", last)
+	}
+
+	rec := serve(s, http.MethodPost, "/events", `{"time": "2026-10-18 10:10", "id": 4, "client": "client1"}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), ErrMixedDates.Error()) {
+		t.Errorf("expected a dated event refused with %v, got %d: %s", ErrMixedDates, rec.Code, rec.Body)
+	}
+}
+
+const serverTestLog = "2\n09:00 19:00\n10\n" +
+	"09:10 1 client1\n" +
+	"09:10 2 client1 1\n" +
+	"09:20 1 client2\n" +
+	"09:20 2 client2 2\n" +
+	"09:30 1 client3\n" +
+	"09:31 3 client3\n"
+
+func TestServerRequests(t *testing.T) {
+	s, _ := newTestServer(t, serverTestLog, time.Date(2026, 10, 18, 10, 10, 0, 0, time.UTC))
+
+	tests := []struct {
+		method, target, body string
+		status               int
+		want                 string
+	}{
+		{http.MethodGet, "/tables", "", http.StatusOK, `{"table":1,"client":"client1","since":"09:10","revenue":0,"occupied":"00:00"}`},
+		{http.MethodPost, "/tables", "", http.StatusMethodNotAllowed, `{"error":"MethodNotAllowed"}`},
+		{http.MethodGet, "/queue", "", http.StatusOK, `[{"position":1,"client":"client3","since":"09:31"}]`},
+		{http.MethodGet, "/revenue", "", http.StatusOK, `{"tables":[{"table":1,"category":"standard","revenue":0,"gross":0,"discount":0,"occupied":"00:00","occupied_minutes":0}`},
+		{http.MethodGet, "/state?at=09:25", "", http.StatusOK, `"queue":[]`},
+		{http.MethodGet, "/state?at=09:00", "", http.StatusNotFound, `{"error":"NoStateBeforeFirstEvent"}`},
+		{http.MethodGet, "/state?at=noon", "", http.StatusBadRequest, `{"error":"InvalidStateTime"}`},
+		{http.MethodGet, "/report?format=text", "", http.StatusOK, "09:31 3 client3\n"},
+		{http.MethodGet, "/report?format=xml", "", http.StatusBadRequest, `"error"`},
+		{http.MethodGet, "/events", "", http.StatusMethodNotAllowed, `{"error":"MethodNotAllowed"}`},
+		{http.MethodPost, "/events", `{"id": 2`, http.StatusBadRequest, `"error"`},
+		{http.MethodPost, "/events", `{"id": 20, "client": "client1"}`, http.StatusBadRequest, `{"error":"UnknownEvent"}`},
+		{http.MethodPost, "/events", `{"id": 4, "client": "client1"}`, http.StatusOK, `[{"time":"10:10","id":12,"client":"client3","table":1,"generated":true}]`},
+		{http.MethodGet, "/close", "", http.StatusMethodNotAllowed, `{"error":"MethodNotAllowed"}`},
+		{http.MethodPost, "/close", "", http.StatusOK, `{"table":2,"category":"standard","revenue":100,"gross":100,"discount":0,"occupied":"09:40","occupied_minutes":580}`},
+		{http.MethodPost, "/close", "", http.StatusOK, `{"tables":[]}`},
+		{http.MethodPost, "/stream", "", http.StatusMethodNotAllowed, `{"error":"MethodNotAllowed"}`},
+	}
+
+	for _, tt := range tests {
+		rec := serve(s, tt.method, tt.target, tt.body)
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s %s: expected %d with %s, got %d: %s", tt.method, tt.target, tt.status, tt.want, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	s, _ := newTestServer(t, serverTestLog, time.Date(2026, 10, 18, 10, 10, 0, 0, time.UTC))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/stream", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if rec := serve(s, http.MethodPost, "/events", `{"id": 4, "client": "client2"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var kinds []string
+	var seated TableState
+	scanner := bufio.NewScanner(resp.Body)
+	for seated.TableID == 0 && scanner.Scan() {
+		line := scanner.Text()
+		if kind := strings.TrimPrefix(line, "event: "); kind != line {
+			kinds = append(kinds, kind)
+		}
+
+		if data := strings.TrimPrefix(line, "data: "); data != line && strings.HasPrefix(data, `{"kind":"table"`) {
+			var u Update
+			if err := json.Unmarshal([]byte(data), &u); err != nil {
+				t.Fatalf("failed to decode %s: %v", data, err)
+			}
+			seated = *u.Table
+		}
+	}
+
+	if strings.Join(kinds, ",") != "event,event,table" {
+		t.Fatalf("expected the incoming and the generated event, then a table change, got %v", kinds)
+	}
+	if seated.TableID != 2 || seated.Client != "client3" || seated.Revenue != 10 {
+		t.Errorf("expected table 2 given to client3 after earning 10, got %+v", seated)
+	}
+}
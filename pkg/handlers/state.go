@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/table"
+)
+
+var (
+	ErrInvalidStateTime = errors.New("InvalidStateTime")
+	ErrNoState          = errors.New("NoStateBeforeFirstEvent")
+)
+
+// State is the club at a moment: who sits at which table, who waits in the
+// queue and how much the tables have earned so far. Revenue counts the
+// finished sessions and the sessions in progress up to the moment.
+type State struct {
+	Time    time.Time
+	Tables  []TableState
+	Queue   []QueueState
+	Revenue int
+
+	// sessions are the sessions in progress, kept to price them at a later
+	// moment.
+	sessions []table.Table
+}
+
+type QueueState struct {
+	Position int    `json:"position"`
+	Client   string `json:"client"`
+	Since    string `json:"since"`
+}
+
+type jsonState struct {
+	Time    string       `json:"time"`
+	Tables  []TableState `json:"tables"`
+	Queue   []QueueState `json:"queue"`
+	Revenue int          `json:"revenue"`
+}
+
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonState{
+		Time:    FormatStateTime(s.Time),
+		Tables:  s.Tables,
+		Queue:   s.Queue,
+		Revenue: s.Revenue,
+	})
+}
+
+// FormatStateTime prints the moment with its date when the log is dated.
+func FormatStateTime(t time.Time) string {
+	if t.Year() == 0 {
+		return t.Format(club.TimeFormat)
+	}
+	return t.Format(club.DateTimeFormat)
+}
+
+// ParseStateTime reads a moment as HH:MM or YYYY-MM-DD HH:MM. Clock times
+// after midnight of an overnight club belong to the next day.
+func ParseStateTime(value string, activeClub *club.Club) (time.Time, error) {
+	if t, err := time.Parse(club.DateTimeFormat, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(club.TimeFormat, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidStateTime
+	}
+	return activeClub.WorkingTime.Normalize(t), nil
+}
+
+// snapshot captures the current state of the club. Revenue is of the
+// finished sessions only, see StateAt.
+func (h *CommandHandler) snapshot(t time.Time) State {
+	state := State{
+		Time:   t,
+		Tables: make([]TableState, 0, h.Club.MaxTables),
+		Queue:  []QueueState{},
+	}
+
+	tables := h.Tables.GetAll()
+	for tableID := 1; tableID <= h.Club.MaxTables; tableID++ {
+		state.Tables = append(state.Tables, newTableState(tableID, tables[tableID]))
+		if tbl, ok := tables[tableID]; ok {
+			state.Revenue += tbl.Revenue
+			if tbl.ClientName != "" {
+				state.sessions = append(state.sessions, tbl.Copy())
+			}
+		}
+	}
+
+	for i, entry := range h.Queue.Entries() {
+		state.Queue = append(state.Queue, QueueState{
+			Position: i + 1,
+			Client:   entry.Username,
+			Since:    entry.EnqueuedAt.Format(club.TimeFormat),
+		})
+	}
+
+	return state
+}
+
+// record keeps the state of the club after an event for the point-in-time
+// queries. A state no different from the last one recorded is not kept
+// again. The states recorded for moments after t are dropped: the closing
+// of a day is recorded after the events that came too late for it.
+func (h *CommandHandler) record(t time.Time) {
+	i := sort.Search(len(h.history), func(i int) bool {
+		return h.history[i].Time.After(t)
+	})
+	h.history = h.history[:i]
+
+	state := h.snapshot(t)
+	if i != 0 && sameState(h.history[i-1], state) {
+		return
+	}
+	h.history = append(h.history, state)
+}
+
+// trimHistory forgets the states of the working days before the latest
+// HistoryDays closed ones.
+func (h *CommandHandler) trimHistory() {
+	if h.HistoryDays == 0 || len(h.days) < h.HistoryDays {
+		return
+	}
+
+	first := h.days[len(h.days)-h.HistoryDays].Open
+	i := sort.Search(len(h.history), func(i int) bool {
+		return !h.history[i].Time.Before(first)
+	})
+	h.history = append([]State(nil), h.history[i:]...)
+}
+
+func sameState(a, b State) bool {
+	if a.Revenue != b.Revenue || len(a.Tables) != len(b.Tables) || len(a.Queue) != len(b.Queue) {
+		return false
+	}
+
+	if !reflect.DeepEqual(a.sessions, b.sessions) {
+		return false
+	}
+
+	for i := range a.Tables {
+		if a.Tables[i] != b.Tables[i] {
+			return false
+		}
+	}
+
+	for i := range a.Queue {
+		if a.Queue[i] != b.Queue[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// StateAt returns the club as it was at the given moment, after all the
+// events of that minute, with the sessions in progress charged up to it. A time without a date in a dated log refers to the
+// first working day. It reports false for a moment before the first event.
+func (h *CommandHandler) StateAt(t time.Time) (State, bool) {
+	if len(h.history) == 0 {
+		return State{}, false
+	}
+
+	first := h.history[0].Time
+	if t.Year() == 0 && first.Year() != 0 {
+		t = h.Club.WorkingTime.DayOf(first).Add(t.Sub(time.Date(0, 1, 1, 0, 0, 0, 0, t.Location())))
+	}
+
+	i := sort.Search(len(h.history), func(i int) bool {
+		return h.history[i].Time.After(t)
+	})
+	if i == 0 {
+		return State{}, false
+	}
+
+	state := h.history[i-1]
+	state.Time = t
+	for j := range state.sessions {
+		state.Revenue += h.Tables.Due(&state.sessions[j], h.Club.Price, t)
+	}
+	return state, true
+}
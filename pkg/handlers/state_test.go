@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStateAt(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n09:10 1 client1\n09:10 2 client1 1\n09:30 1 client2\n09:30 3 client2\n11:10 4 client1\n")
+	h.Report()
+
+	at := func(value string) State {
+		t.Helper()
+
+		moment, err := ParseStateTime(value, h.Club)
+		if err != nil {
+			t.Fatalf("ParseStateTime(%s): %v", value, err)
+		}
+
+		state, ok := h.StateAt(moment)
+		if !ok {
+			t.Fatalf("expected a state at %s", value)
+		}
+		return state
+	}
+
+	if _, ok := h.StateAt(h.Managers[0].Time.Add(-1)); ok {
+		t.Error("expected no state before the first event")
+	}
+
+	morning := at("10:00")
+	if morning.Tables[0].Client != "client1" || morning.Tables[0].Since != "09:10" {
+		t.Errorf("expected client1 at table 1, got %+v", morning.Tables[0])
+	}
+	if len(morning.Queue) != 1 || morning.Queue[0].Client != "client2" || morning.Revenue != 10 {
+		t.Errorf("expected client2 to wait and the first hour of client1 charged, got %+v", morning)
+	}
+
+	noon := at("11:10")
+	if noon.Tables[0].Client != "client2" || len(noon.Queue) != 0 || noon.Revenue != 20 {
+		t.Errorf("expected client2 at table 1 after client1 left, got %+v", noon)
+	}
+
+	if afternoon := at("12:30"); afternoon.Revenue != 40 || afternoon.Tables[0].Revenue != 20 {
+		t.Errorf("expected two hours of client1 and two started hours of client2, got %+v", afternoon)
+	}
+
+	if closed := at("19:00"); closed.Tables[0].Client != "" || closed.Revenue != 100 {
+		t.Errorf("expected the club to be empty after closing, got %+v", closed)
+	}
+
+	if _, err := ParseStateTime("25:00", h.Club); !errors.Is(err, ErrInvalidStateTime) {
+		t.Errorf("expected ErrInvalidStateTime, got %v", err)
+	}
+}
+
+func TestStateHistory(t *testing.T) {
+	h := newTestHandler(t, "1\n09:00 19:00\n10\n09:10 1 client1\n09:10 2 client1 1\n09:20 1 client1\n19:30 1 client2\n")
+	h.Report()
+
+	// Arrival, taking the table and closing; the refused arrival and the
+	// event after closing change nothing.
+	if len(h.history) != 3 {
+		t.Errorf("expected 3 different states, got %d", len(h.history))
+	}
+
+	late, err := ParseStateTime("19:30", h.Club)
+	if err != nil {
+		t.Fatalf("ParseStateTime: %v", err)
+	}
+	if state, ok := h.StateAt(late); !ok || state.Tables[0].Client != "" || state.Revenue != 100 {
+		t.Errorf("expected the club closed after an event too late for the day, got %+v", state)
+	}
+
+	h = newTestHandler(t, "1\n09:00 19:00\n10\n"+
+		"2026-10-18 09:10 1 client1\n"+
+		"2026-10-19 09:10 1 client1\n"+
+		"2026-10-20 09:10 1 client1\n")
+	h.HistoryDays = 1
+	h.Report()
+
+	for value, kept := range map[string]bool{"2026-10-18 10:00": false, "2026-10-19 10:00": false, "2026-10-20 10:00": true} {
+		moment, err := ParseStateTime(value, h.Club)
+		if err != nil {
+			t.Fatalf("ParseStateTime(%s): %v", value, err)
+		}
+		if _, ok := h.StateAt(moment); ok != kept {
+			t.Errorf("expected the state at %s kept: %v, got %v", value, kept, ok)
+		}
+	}
+}
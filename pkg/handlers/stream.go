@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+	"github.com/apartapatia/computer_club_assistant/pkg/table"
+)
+
+const (
+	UpdateEvent = "event"
+	UpdateTable = "table"
+
+	subscriberBuffer = 64
+)
+
+// Update is a single change published to the subscribers of a handler:
+// either a processed event, incoming or generated, or the new state of a
+// table.
+type Update struct {
+	Kind  string        `json:"kind"`
+	Event *report.Event `json:"event,omitempty"`
+	Table *TableState   `json:"table,omitempty"`
+}
+
+type TableState struct {
+	TableID  int    `json:"table"`
+	Client   string `json:"client,omitempty"`
+	Since    string `json:"since,omitempty"`
+	Revenue  int    `json:"revenue"`
+	Occupied string `json:"occupied"`
+	Paused   bool   `json:"paused,omitempty"`
+}
+
+// Broadcaster fans the updates out to every subscriber. A subscriber that
+// does not keep up misses updates instead of blocking the club.
+type Broadcaster struct {
+	subscribers map[chan Update]struct{}
+	mu          *sync.Mutex
+}
+
+// Subscribe returns the channel of the updates and the function that
+// cancels the subscription and closes the channel.
+func (b *Broadcaster) Subscribe() (<-chan Update, func()) {
+	ch := make(chan Update, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *Broadcaster) Publish(u Update) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- u:
+		default:
+		}
+	}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subscribers: make(map[chan Update]struct{}),
+		mu:          &sync.Mutex{},
+	}
+}
+
+func newTableState(tableID int, t *table.Table) TableState {
+	state := TableState{TableID: tableID, Occupied: report.FormatDuration(0)}
+	if t != nil {
+		state.Revenue = t.Revenue
+		state.Occupied = t.AllTimeFormatted()
+		if t.ClientName != "" {
+			state.Client = t.ClientName
+			state.Since = t.StartTime.Format(club.TimeFormat)
+			state.Paused = t.Paused()
+		}
+	}
+	return state
+}
+
+// tableStates captures the state of every table to find out later which of
+// them an event has changed. Nothing is captured without subscribers.
+func (h *CommandHandler) tableStates() map[int]TableState {
+	if h.Updates == nil {
+		return nil
+	}
+
+	states := make(map[int]TableState)
+	for tableID, t := range h.Tables.GetAll() {
+		states[tableID] = newTableState(tableID, t)
+	}
+	return states
+}
+
+// publish sends the events and the tables changed since the given states
+// to the subscribers, if there are any.
+func (h *CommandHandler) publish(events []report.Event, before map[int]TableState) {
+	if h.Updates == nil {
+		return
+	}
+
+	for i := range events {
+		h.Updates.Publish(Update{Kind: UpdateEvent, Event: &events[i]})
+	}
+
+	after := h.tableStates()
+	changed := make([]int, 0, len(after))
+	for tableID, state := range after {
+		if state != before[tableID] {
+			changed = append(changed, tableID)
+		}
+	}
+	sort.Ints(changed)
+
+	for _, tableID := range changed {
+		state := after[tableID]
+		h.Updates.Publish(Update{Kind: UpdateTable, Table: &state})
+	}
+}
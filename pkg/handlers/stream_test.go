@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+)
+
+func TestPublishUpdates(t *testing.T) {
+	h := newTestHandler(t, "2\n09:00 19:00\n10\n09:10 1 client1\n09:10 2 client1 1\n10:10 4 client1\n")
+	h.Updates = NewBroadcaster()
+
+	updates, cancel := h.Updates.Subscribe()
+	defer cancel()
+
+	for _, m := range h.Managers {
+		if _, err := h.HandleEvent(m); err != nil {
+			t.Fatalf("HandleEvent: %v", err)
+		}
+	}
+
+	var events, tables []Update
+	for len(updates) > 0 {
+		u := <-updates
+		switch u.Kind {
+		case UpdateEvent:
+			events = append(events, u)
+		case UpdateTable:
+			tables = append(tables, u)
+		}
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	if len(tables) != 2 {
+		t.Fatalf("expected 2 table changes, got %d", len(tables))
+	}
+
+	if taken := tables[0].Table; taken.TableID != 1 || taken.Client != "client1" || taken.Since != "09:10" {
+		t.Errorf("unexpected table state after event 2: %+v", taken)
+	}
+
+	if freed := tables[1].Table; freed.Client != "" || freed.Revenue != 10 {
+		t.Errorf("unexpected table state after event 4: %+v", freed)
+	}
+
+	cancel()
+	if _, ok := <-updates; ok {
+		t.Error("expected the channel to be closed after cancel")
+	}
+}
@@ -0,0 +1,211 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+var ErrCorruptJournal = errors.New("CorruptJournal")
+
+type Kind string
+
+const (
+	KindIncoming  Kind = "incoming"
+	KindGenerated Kind = "generated"
+	KindClose     Kind = "close"
+)
+
+// Record is a single entry of the journal: an accepted incoming event, an
+// event the club generated in response, or the end of a working day.
+type Record struct {
+	Seq     int       `json:"seq"`
+	Kind    Kind      `json:"kind"`
+	Time    time.Time `json:"time"`
+	ID      int       `json:"id,omitempty"`
+	Client  string    `json:"client,omitempty"`
+	TableID int       `json:"table,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Amount  int       `json:"amount,omitempty"`
+	Promo   string    `json:"promo,omitempty"`
+	Package string    `json:"package,omitempty"`
+
+	Reservation *club.Reservation `json:"reservation,omitempty"`
+}
+
+// Handler is the part of the command handler the journal is replayed
+// through.
+type Handler interface {
+	HandleEvent(m *club.Manager) ([]report.Event, error)
+	CloseDay() *report.Day
+}
+
+// Journal appends records to a file, one per line, each prefixed with the
+// CRC-32 checksum of its body. Every record is synced to disk before the
+// append returns.
+type Journal struct {
+	file *os.File
+	seq  int
+	mu   *sync.Mutex
+}
+
+func (j *Journal) Incoming(m *club.Manager) error {
+	return j.append(Record{Kind: KindIncoming, Time: m.Time, ID: m.ID, Client: m.Client.Username, TableID: m.TableID, Amount: m.Amount, Promo: m.PromoCode, Package: m.Package, Reservation: m.Reservation})
+}
+
+func (j *Journal) Generated(events []report.Event) error {
+	for _, e := range events {
+		if !e.Generated {
+			continue
+		}
+
+		err := j.append(Record{Kind: KindGenerated, Time: e.Time, ID: e.ID, Client: e.Client, TableID: e.TableID, Error: e.Error})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *Journal) CloseDay(day time.Time) error {
+	return j.append(Record{Kind: KindClose, Time: day})
+}
+
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+func (j *Journal) append(r Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	r.Seq = j.seq + 1
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(j.file, "%08x %s\n", crc32.ChecksumIEEE(body), body); err != nil {
+		return err
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+
+	j.seq = r.Seq
+	return nil
+}
+
+// Read returns the records of the journal and the size of the part that
+// holds them. A damaged last line is the trace of an interrupted write and
+// is left out; damage anywhere else is reported as ErrCorruptJournal.
+func Read(r io.Reader) ([]Record, int64, error) {
+	var records []Record
+	var valid int64
+
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without the newline was cut short.
+			return records, valid, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		record, ok := decode(data)
+		if !ok {
+			if _, err := reader.Peek(1); err == io.EOF {
+				return records, valid, nil
+			}
+			return nil, 0, fmt.Errorf("%w: line %d", ErrCorruptJournal, line)
+		}
+
+		records = append(records, record)
+		valid += int64(len(data))
+	}
+}
+
+func decode(data []byte) (Record, bool) {
+	var record Record
+
+	checksum, body, ok := bytes.Cut(bytes.TrimSuffix(data, []byte("\n")), []byte(" "))
+	if !ok || fmt.Sprintf("%08x", crc32.ChecksumIEEE(body)) != string(checksum) {
+		return record, false
+	}
+
+	if err := json.Unmarshal(body, &record); err != nil {
+		return record, false
+	}
+	return record, true
+}
+
+// Replay feeds the incoming events and the ends of working days through
+// the handler, which rebuilds its state and generates the same events
+// again. It returns the number of events replayed.
+func Replay(records []Record, h Handler) (int, error) {
+	replayed := 0
+
+	for _, r := range records {
+		switch r.Kind {
+		case KindIncoming:
+			m := club.NewManager(r.Time, r.ID, r.Client, r.TableID)
+			m.Amount = r.Amount
+			m.PromoCode = r.Promo
+			m.Package = r.Package
+			m.Reservation = r.Reservation
+			if _, err := h.HandleEvent(m); err != nil {
+				return replayed, fmt.Errorf("record %d: %w", r.Seq, err)
+			}
+			replayed++
+		case KindClose:
+			h.CloseDay()
+		}
+	}
+
+	return replayed, nil
+}
+
+// NewJournal opens the journal at path, creating it if needed, and returns
+// it together with the records it already holds. A record cut short by a
+// crash is dropped from the file.
+func NewJournal(path string) (*Journal, []Record, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, valid, err := Read(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	j := &Journal{file: file, mu: &sync.Mutex{}}
+	if len(records) != 0 {
+		j.seq = records[len(records)-1].Seq
+	}
+	return j, records, nil
+}
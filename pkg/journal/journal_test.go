@@ -0,0 +1,139 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/report"
+)
+
+type recordingHandler struct {
+	events []string
+	closed int
+}
+
+func (h *recordingHandler) HandleEvent(m *club.Manager) ([]report.Event, error) {
+	h.events = append(h.events, report.NewIncomingEvent(m).String())
+	return nil, nil
+}
+
+func (h *recordingHandler) CloseDay() *report.Day {
+	h.closed++
+	return nil
+}
+
+func writeJournal(t *testing.T, path string) {
+	t.Helper()
+
+	j, records, err := NewJournal(path)
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	defer j.Close()
+
+	if len(records) != 0 {
+		t.Fatalf("expected a new journal to be empty, got %d records", len(records))
+	}
+
+	at := time.Date(0, 1, 1, 9, 30, 0, 0, time.UTC)
+	if err := j.Incoming(club.NewManager(at, 1, "client1", 0)); err != nil {
+		t.Fatalf("Incoming: %v", err)
+	}
+	if err := j.Generated([]report.Event{report.NewErrorEvent(at, 13, errors.New("NotOpenYet"))}); err != nil {
+		t.Fatalf("Generated: %v", err)
+	}
+	if err := j.Incoming(club.NewManager(at.Add(time.Hour), 2, "client1", 1)); err != nil {
+		t.Fatalf("Incoming: %v", err)
+	}
+	if err := j.CloseDay(time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("CloseDay: %v", err)
+	}
+}
+
+func TestJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "club.journal")
+	writeJournal(t, path)
+
+	j, records, err := NewJournal(path)
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	defer j.Close()
+
+	if len(records) != 4 || records[3].Seq != 4 || records[1].Kind != KindGenerated {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	h := &recordingHandler{}
+	replayed, err := Replay(records, h)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if replayed != 2 || h.closed != 1 {
+		t.Errorf("expected 2 events and 1 closed day, got %d and %d", replayed, h.closed)
+	}
+
+	expected := []string{"09:30 1 client1", "10:30 2 client1 1"}
+	for i, e := range expected {
+		if h.events[i] != e {
+			t.Errorf("event %d: expected %q, got %q", i, e, h.events[i])
+		}
+	}
+}
+
+func TestJournalTornWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "club.journal")
+	writeJournal(t, path)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	_, _ = file.WriteString(`0badc0de {"seq":5,"kind":"inc`)
+	file.Close()
+
+	j, records, err := NewJournal(path)
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+
+	if len(records) != 4 {
+		t.Fatalf("expected the torn record to be dropped, got %d records", len(records))
+	}
+
+	if err := j.CloseDay(time.Time{}); err != nil {
+		t.Fatalf("CloseDay: %v", err)
+	}
+	j.Close()
+
+	_, records, err = NewJournal(path)
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	if len(records) != 5 || records[4].Seq != 5 {
+		t.Errorf("expected the journal to continue after the last valid record, got %+v", records)
+	}
+}
+
+func TestJournalCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "club.journal")
+	writeJournal(t, path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read journal: %v", err)
+	}
+	data[20] ^= 1
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write journal: %v", err)
+	}
+
+	if _, _, err := NewJournal(path); !errors.Is(err, ErrCorruptJournal) {
+		t.Errorf("expected ErrCorruptJournal, got %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnknownPolicy = errors.New("UnknownQueuePolicy")
+
+const (
+	PolicyFIFO            = "fifo"
+	PolicyVIP             = "vip"
+	PolicyShortestSession = "shortest"
+)
+
+// Policy orders the waiting clients. Less reports whether a must be served
+// before b; clients the policy does not distinguish keep arrival order.
+type Policy interface {
+	Name() string
+	Less(a, b *Entry) bool
+}
+
+type FIFOPolicy struct{}
+
+func (p FIFOPolicy) Name() string {
+	return PolicyFIFO
+}
+
+func (p FIFOPolicy) Less(a, b *Entry) bool {
+	return false
+}
+
+type VIPPolicy struct{}
+
+func (p VIPPolicy) Name() string {
+	return PolicyVIP
+}
+
+func (p VIPPolicy) Less(a, b *Entry) bool {
+	return a.VIP && !b.VIP
+}
+
+// ShortestSessionPolicy serves clients with the shortest expected session
+// first. Clients without an expectation go after everyone who has one.
+type ShortestSessionPolicy struct{}
+
+func (p ShortestSessionPolicy) Name() string {
+	return PolicyShortestSession
+}
+
+func (p ShortestSessionPolicy) Less(a, b *Entry) bool {
+	if a.ExpectedDuration == 0 {
+		return false
+	}
+	return b.ExpectedDuration == 0 || a.ExpectedDuration < b.ExpectedDuration
+}
+
+func PolicyByName(name string) (Policy, error) {
+	switch strings.ToLower(name) {
+	case "", PolicyFIFO:
+		return FIFOPolicy{}, nil
+	case PolicyVIP:
+		return VIPPolicy{}, nil
+	case PolicyShortestSession:
+		return ShortestSessionPolicy{}, nil
+	}
+	return nil, ErrUnknownPolicy
+}
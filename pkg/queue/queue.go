@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+type Entry struct {
+	Username         string
+	EnqueuedAt       time.Time
+	VIP              bool
+	ExpectedDuration time.Duration
+}
+
+// Queue keeps the clients waiting for a free table in the order they are
+// served.
+type Queue interface {
+	Push(entry *Entry)
+	Remove(username string) bool
+	Position(username string) (int, bool)
+	Len() int
+	Entries() []*Entry
+}
+
+type QueueMemory struct {
+	entries []*Entry
+	policy  Policy
+	mu      sync.RWMutex
+}
+
+// Push places the client behind everyone the policy serves first. A client
+// that is already waiting keeps its place.
+func (q *QueueMemory) Push(entry *Entry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.indexOf(entry.Username) != -1 {
+		return
+	}
+
+	i := len(q.entries)
+	for i > 0 && q.policy.Less(entry, q.entries[i-1]) {
+		i--
+	}
+
+	q.entries = append(q.entries, nil)
+	copy(q.entries[i+1:], q.entries[i:])
+	q.entries[i] = entry
+}
+
+func (q *QueueMemory) Policy() Policy {
+	return q.policy
+}
+
+func (q *QueueMemory) Remove(username string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	i := q.indexOf(username)
+	if i == -1 {
+		return false
+	}
+
+	q.entries = append(q.entries[:i], q.entries[i+1:]...)
+	return true
+}
+
+// Position returns the 1-based place of the client in the queue.
+func (q *QueueMemory) Position(username string) (int, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	i := q.indexOf(username)
+	if i == -1 {
+		return 0, false
+	}
+	return i + 1, true
+}
+
+func (q *QueueMemory) Len() int {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	return len(q.entries)
+}
+
+func (q *QueueMemory) Entries() []*Entry {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	entries := make([]*Entry, len(q.entries))
+	copy(entries, q.entries)
+	return entries
+}
+
+func (q *QueueMemory) indexOf(username string) int {
+	for i, entry := range q.entries {
+		if entry.Username == username {
+			return i
+		}
+	}
+	return -1
+}
+
+func NewMemoryQueue(policy Policy) *QueueMemory {
+	if policy == nil {
+		policy = FIFOPolicy{}
+	}
+
+	return &QueueMemory{
+		policy: policy,
+	}
+}
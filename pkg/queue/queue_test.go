@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueMemoryFIFO(t *testing.T) {
+	q := NewMemoryQueue(FIFOPolicy{})
+
+	start, _ := time.Parse("15:04", "12:00")
+	q.Push(&Entry{Username: "zoe", EnqueuedAt: start})
+	q.Push(&Entry{Username: "adam", EnqueuedAt: start.Add(time.Minute)})
+	q.Push(&Entry{Username: "mike", EnqueuedAt: start.Add(2 * time.Minute)})
+	q.Push(&Entry{Username: "zoe", EnqueuedAt: start.Add(3 * time.Minute)})
+
+	if q.Len() != 3 {
+		t.Fatalf("Expected 3 waiting clients, got %d", q.Len())
+	}
+
+	if pos, ok := q.Position("mike"); !ok || pos != 3 {
+		t.Errorf("Expected mike at position 3, got %d", pos)
+	}
+
+	if first := q.Entries()[0]; first.Username != "zoe" || !first.EnqueuedAt.Equal(start) {
+		t.Errorf("Expected zoe enqueued at %v to be served first, got %+v", start, first)
+	}
+
+	q.Remove("zoe")
+	q.Remove("adam")
+
+	if pos, ok := q.Position("mike"); !ok || pos != 1 {
+		t.Errorf("Expected mike at position 1, got %d", pos)
+	}
+}
+
+func TestQueueMemoryPolicies(t *testing.T) {
+	vip := NewMemoryQueue(VIPPolicy{})
+	vip.Push(&Entry{Username: "anna"})
+	vip.Push(&Entry{Username: "boris", VIP: true})
+	vip.Push(&Entry{Username: "clara"})
+	vip.Push(&Entry{Username: "dima", VIP: true})
+
+	expected := []string{"boris", "dima", "anna", "clara"}
+	for i, entry := range vip.Entries() {
+		if entry.Username != expected[i] {
+			t.Errorf("VIP policy: expected %s at position %d, got %s", expected[i], i+1, entry.Username)
+		}
+	}
+
+	shortest := NewMemoryQueue(ShortestSessionPolicy{})
+	shortest.Push(&Entry{Username: "anna"})
+	shortest.Push(&Entry{Username: "boris", ExpectedDuration: 2 * time.Hour})
+	shortest.Push(&Entry{Username: "clara", ExpectedDuration: 30 * time.Minute})
+
+	expected = []string{"clara", "boris", "anna"}
+	for i, entry := range shortest.Entries() {
+		if entry.Username != expected[i] {
+			t.Errorf("Shortest session policy: expected %s at position %d, got %s", expected[i], i+1, entry.Username)
+		}
+	}
+}
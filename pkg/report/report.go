@@ -0,0 +1,297 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+	"github.com/apartapatia/computer_club_assistant/pkg/table"
+)
+
+// Event is a single line of the day log: either an incoming event echoed
+// from the input or an event generated by the club (ids 11 to 17). Details
+// holds the rest of a booking: the table or category, start and length, the
+// amount of a top-up, the promo code of an arrival or the package bought.
+type Event struct {
+	Time      time.Time
+	ID        int
+	Client    string
+	TableID   int
+	Details   string
+	Error     string
+	Generated bool
+}
+
+func (e Event) String() string {
+	switch {
+	case e.Details != "":
+		return fmt.Sprintf("%s %d %s %s", e.Time.Format(club.TimeFormat), e.ID, e.Client, e.Details)
+	case e.Error != "":
+		return fmt.Sprintf("%s %d %s", e.Time.Format(club.TimeFormat), e.ID, e.Error)
+	case e.TableID != 0:
+		return fmt.Sprintf("%s %d %s %d", e.Time.Format(club.TimeFormat), e.ID, e.Client, e.TableID)
+	default:
+		return fmt.Sprintf("%s %d %s", e.Time.Format(club.TimeFormat), e.ID, e.Client)
+	}
+}
+
+// BandRevenue is the part of the table revenue charged in one tariff band.
+type BandRevenue struct {
+	Name    string
+	Revenue int
+}
+
+// TableSummary describes one table: Revenue is collected after Discount
+// was taken off, the bands split the revenue before the discount.
+type TableSummary struct {
+	TableID  int
+	Category string
+	Revenue  int
+	Discount int
+	Occupied time.Duration
+	Bands    []BandRevenue
+}
+
+// Gross is the revenue of the table before discounts.
+func (t TableSummary) Gross() int {
+	return t.Revenue + t.Discount
+}
+
+// CategorySummary adds up the tables of one category.
+type CategorySummary struct {
+	Name     string
+	Tables   int
+	Revenue  int
+	Occupied time.Duration
+}
+
+// ClientSummary describes the visits of one client: the time played and
+// the amount owed over all sessions, the table changes, the time spent in
+// the queue and whether the club sent the client away (event 11).
+type ClientSummary struct {
+	Client       string
+	Visits       int
+	Played       time.Duration
+	Amount       int
+	TableChanges int
+	Waited       time.Duration
+	Ejected      bool
+}
+
+// LedgerEntry is the prepaid balance of one client over a working day: the
+// balance at opening, the top-ups, the sessions charged and the balance at
+// closing.
+type LedgerEntry struct {
+	Client  string
+	Opening int
+	TopUps  int
+	Charges int
+	Closing int
+}
+
+func (c CategorySummary) OccupiedFormatted() string {
+	return FormatDuration(c.Occupied)
+}
+
+func (t TableSummary) OccupiedFormatted() string {
+	return FormatDuration(t.Occupied)
+}
+
+// Day is one working day of the club: from opening to closing. Date has a
+// zero year for logs without dates.
+type Day struct {
+	Date       time.Time
+	Open       time.Time
+	Close      time.Time
+	Events     []Event
+	Sessions   []table.Session
+	Tables     []TableSummary
+	Categories []CategorySummary
+	Clients    []ClientSummary
+	Ledger     []LedgerEntry
+}
+
+func (d *Day) Dated() bool {
+	return d.Date.Year() != 0
+}
+
+// Report holds the processed days together with the table and category
+// totals for the whole period.
+type Report struct {
+	Days       []*Day
+	Tables     []TableSummary
+	Categories []CategorySummary
+	Clients    []ClientSummary
+}
+
+// MultiDay reports whether the report covers more than one working day.
+func (r *Report) MultiDay() bool {
+	return len(r.Days) > 1
+}
+
+func FormatDuration(d time.Duration) string {
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	return fmt.Sprintf("%02d:%02d", hours, minutes)
+}
+
+func NewIncomingEvent(m *club.Manager) Event {
+	e := Event{
+		Time:    m.Time,
+		ID:      m.ID,
+		Client:  m.Client.Username,
+		TableID: m.TableID,
+	}
+
+	if r := m.Reservation; r != nil {
+		target := r.Category
+		if r.TableID != 0 {
+			target = strconv.Itoa(r.TableID)
+		}
+		e.Details = fmt.Sprintf("%s %s %d", target, r.Start.Format(club.TimeFormat), int(r.Duration.Minutes()))
+	}
+
+	if m.Amount != 0 {
+		e.Details = strconv.Itoa(m.Amount)
+	}
+
+	if m.PromoCode != "" {
+		e.Details = m.PromoCode
+	}
+
+	if m.Package != "" {
+		e.Details = m.Package
+	}
+	return e
+}
+
+func NewOutgoingEvent(t time.Time, id int, username string, tableID int) Event {
+	return Event{
+		Time:      t,
+		ID:        id,
+		Client:    username,
+		TableID:   tableID,
+		Generated: true,
+	}
+}
+
+func NewErrorEvent(t time.Time, id int, err error) Event {
+	return Event{
+		Time:      t,
+		ID:        id,
+		Error:     err.Error(),
+		Generated: true,
+	}
+}
+
+type jsonEvent struct {
+	Time      string `json:"time"`
+	ID        int    `json:"id"`
+	Client    string `json:"client,omitempty"`
+	TableID   int    `json:"table,omitempty"`
+	Details   string `json:"details,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Generated bool   `json:"generated"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonEvent{
+		Time:      e.Time.Format(club.TimeFormat),
+		ID:        e.ID,
+		Client:    e.Client,
+		TableID:   e.TableID,
+		Details:   e.Details,
+		Error:     e.Error,
+		Generated: e.Generated,
+	})
+}
+
+type jsonClient struct {
+	Client        string `json:"client"`
+	Visits        int    `json:"visits"`
+	Played        string `json:"played"`
+	PlayedMinutes int    `json:"played_minutes"`
+	Amount        int    `json:"amount"`
+	TableChanges  int    `json:"table_changes"`
+	Waited        string `json:"waited"`
+	WaitedMinutes int    `json:"waited_minutes"`
+	Ejected       bool   `json:"ejected"`
+}
+
+func (c ClientSummary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonClient{
+		Client:        c.Client,
+		Visits:        c.Visits,
+		Played:        FormatDuration(c.Played),
+		PlayedMinutes: int(c.Played.Minutes()),
+		Amount:        c.Amount,
+		TableChanges:  c.TableChanges,
+		Waited:        FormatDuration(c.Waited),
+		WaitedMinutes: int(c.Waited.Minutes()),
+		Ejected:       c.Ejected,
+	})
+}
+
+type jsonLedgerEntry struct {
+	Client  string `json:"client"`
+	Opening int    `json:"opening"`
+	TopUps  int    `json:"top_ups"`
+	Charges int    `json:"charges"`
+	Closing int    `json:"closing"`
+}
+
+func (l LedgerEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonLedgerEntry(l))
+}
+
+type jsonBand struct {
+	Name    string `json:"name"`
+	Revenue int    `json:"revenue"`
+}
+
+type jsonTable struct {
+	TableID         int        `json:"table"`
+	Category        string     `json:"category,omitempty"`
+	Revenue         int        `json:"revenue"`
+	Gross           int        `json:"gross"`
+	Discount        int        `json:"discount"`
+	Occupied        string     `json:"occupied"`
+	OccupiedMinutes int        `json:"occupied_minutes"`
+	Bands           []jsonBand `json:"bands,omitempty"`
+}
+
+func (t TableSummary) MarshalJSON() ([]byte, error) {
+	table := jsonTable{
+		TableID:         t.TableID,
+		Category:        t.Category,
+		Revenue:         t.Revenue,
+		Gross:           t.Gross(),
+		Discount:        t.Discount,
+		Occupied:        t.OccupiedFormatted(),
+		OccupiedMinutes: int(t.Occupied.Minutes()),
+	}
+	for _, b := range t.Bands {
+		table.Bands = append(table.Bands, jsonBand{Name: b.Name, Revenue: b.Revenue})
+	}
+	return json.Marshal(table)
+}
+
+type jsonCategory struct {
+	Name            string `json:"name"`
+	Tables          int    `json:"tables"`
+	Revenue         int    `json:"revenue"`
+	Occupied        string `json:"occupied"`
+	OccupiedMinutes int    `json:"occupied_minutes"`
+}
+
+func (c CategorySummary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCategory{
+		Name:            c.Name,
+		Tables:          c.Tables,
+		Revenue:         c.Revenue,
+		Occupied:        c.OccupiedFormatted(),
+		OccupiedMinutes: int(c.Occupied.Minutes()),
+	})
+}
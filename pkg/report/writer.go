@@ -0,0 +1,410 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+var ErrUnknownFormat = errors.New("UnknownOutputFormat")
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+type Writer interface {
+	Write(w io.Writer, r *Report) error
+}
+
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatText, "txt":
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+func NewWriter(format Format) Writer {
+	switch format {
+	case FormatJSON:
+		return &JSONWriter{}
+	case FormatCSV:
+		return &CSVWriter{}
+	}
+	return &TextWriter{}
+}
+
+// TextWriter renders the report in the line layout of the input file. A
+// report covering several days prints every day under its date followed by
+// the totals for the whole period. With Clients set, every summary ends
+// with a line per client. A day with prepaid accounts ends with its ledger.
+type TextWriter struct {
+	Clients bool
+}
+
+func (tw *TextWriter) Write(w io.Writer, r *Report) error {
+	var sb strings.Builder
+
+	if !r.MultiDay() {
+		for _, d := range r.Days {
+			writeTextDay(&sb, d.Open, d.Close, d.Events)
+		}
+		writeTextSummary(&sb, r.Tables, r.Categories)
+		tw.writeClients(&sb, r.Clients)
+		for _, d := range r.Days {
+			writeLedger(&sb, d.Ledger)
+		}
+
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	for _, d := range r.Days {
+		sb.WriteString(d.Date.Format(club.DateFormat) + "\n")
+		writeTextDay(&sb, d.Open, d.Close, d.Events)
+		writeTextSummary(&sb, d.Tables, d.Categories)
+		tw.writeClients(&sb, d.Clients)
+		writeLedger(&sb, d.Ledger)
+	}
+
+	sb.WriteString("total\n")
+	writeTextSummary(&sb, r.Tables, r.Categories)
+	tw.writeClients(&sb, r.Clients)
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func writeTextDay(sb *strings.Builder, open, close time.Time, events []Event) {
+	sb.WriteString(open.Format(club.TimeFormat) + "\n")
+
+	for _, e := range events {
+		sb.WriteString(e.String() + "\n")
+	}
+
+	sb.WriteString(close.Format(club.TimeFormat) + "\n")
+}
+
+func writeTextSummary(sb *strings.Builder, tables []TableSummary, categories []CategorySummary) {
+	for _, t := range tables {
+		sb.WriteString(fmt.Sprintf("%d %d %s", t.TableID, t.Revenue, t.OccupiedFormatted()))
+		if t.Discount != 0 {
+			sb.WriteString(fmt.Sprintf(" gross:%d discount:%d", t.Gross(), t.Discount))
+		}
+		for _, b := range t.Bands {
+			sb.WriteString(fmt.Sprintf(" %s:%d", b.Name, b.Revenue))
+		}
+		sb.WriteString("\n")
+	}
+
+	for _, c := range categories {
+		sb.WriteString(fmt.Sprintf("%s %d %s\n", c.Name, c.Revenue, c.OccupiedFormatted()))
+	}
+}
+
+// writeClients prints the name, amount owed, time played, table changes and
+// time waited of every client, marking the ones the club sent away.
+func (tw *TextWriter) writeClients(sb *strings.Builder, clients []ClientSummary) {
+	if !tw.Clients {
+		return
+	}
+
+	for _, c := range clients {
+		sb.WriteString(fmt.Sprintf("%s %d %s %d %s", c.Client, c.Amount, FormatDuration(c.Played), c.TableChanges, FormatDuration(c.Waited)))
+		if c.Ejected {
+			sb.WriteString(" ejected")
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// writeLedger prints the opening balance, top-ups, charges and closing
+// balance of every prepaid account.
+func writeLedger(sb *strings.Builder, ledger []LedgerEntry) {
+	for _, l := range ledger {
+		sb.WriteString(fmt.Sprintf("%s %d +%d -%d %d\n", l.Client, l.Opening, l.TopUps, l.Charges, l.Closing))
+	}
+}
+
+type jsonDay struct {
+	Date       string            `json:"date,omitempty"`
+	Open       string            `json:"open"`
+	Close      string            `json:"close"`
+	Events     []Event           `json:"events"`
+	Sessions   []jsonSession     `json:"sessions"`
+	Tables     []TableSummary    `json:"tables"`
+	Categories []CategorySummary `json:"categories,omitempty"`
+	Clients    []ClientSummary   `json:"clients"`
+	Ledger     []LedgerEntry     `json:"ledger,omitempty"`
+}
+
+type jsonSession struct {
+	Client        string `json:"client"`
+	TableID       int    `json:"table"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Duration      string `json:"duration"`
+	BilledMinutes int    `json:"billed_minutes"`
+	Amount        int    `json:"amount"`
+	Gross         int    `json:"gross"`
+	Discount      int    `json:"discount"`
+	Package       string `json:"package,omitempty"`
+	PausedMinutes int    `json:"paused_minutes"`
+	QueuedIn      bool   `json:"queued_in"`
+	Reason        string `json:"reason"`
+}
+
+type jsonReport struct {
+	Days       []jsonDay         `json:"days"`
+	Tables     []TableSummary    `json:"tables"`
+	Categories []CategorySummary `json:"categories,omitempty"`
+	Clients    []ClientSummary   `json:"clients"`
+}
+
+type JSONWriter struct{}
+
+func (jw *JSONWriter) Write(w io.Writer, r *Report) error {
+	out := jsonReport{
+		Days:       make([]jsonDay, 0, len(r.Days)),
+		Tables:     r.Tables,
+		Categories: r.Categories,
+		Clients:    nonNilClients(r.Clients),
+	}
+
+	for _, d := range r.Days {
+		day := jsonDay{
+			Open:       d.Open.Format(club.TimeFormat),
+			Close:      d.Close.Format(club.TimeFormat),
+			Events:     d.Events,
+			Sessions:   make([]jsonSession, 0, len(d.Sessions)),
+			Tables:     d.Tables,
+			Categories: d.Categories,
+			Clients:    nonNilClients(d.Clients),
+			Ledger:     d.Ledger,
+		}
+		if d.Dated() {
+			day.Date = d.Date.Format(club.DateFormat)
+		}
+		if day.Events == nil {
+			day.Events = []Event{}
+		}
+
+		for _, s := range d.Sessions {
+			day.Sessions = append(day.Sessions, jsonSession{
+				Client:        s.Client,
+				TableID:       s.TableID,
+				Start:         s.Start.Format(club.TimeFormat),
+				End:           s.End.Format(club.TimeFormat),
+				Duration:      FormatDuration(s.Duration()),
+				BilledMinutes: int(s.Billed.Minutes()),
+				Amount:        s.Amount,
+				Gross:         s.Gross(),
+				Discount:      s.Discount,
+				Package:       s.Package,
+				PausedMinutes: int(s.Paused.Minutes()),
+				QueuedIn:      s.QueuedIn,
+				Reason:        string(s.Reason),
+			})
+		}
+
+		out.Days = append(out.Days, day)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func nonNilClients(clients []ClientSummary) []ClientSummary {
+	if clients == nil {
+		return []ClientSummary{}
+	}
+	return clients
+}
+
+// CSVWriter writes one row per record; the first column tells events apart
+// from the opening, closing, session, ledger, per-table, per-category and
+// per-client rows. Rows of a single day carry its date, the period totals
+// have none.
+type CSVWriter struct{}
+
+var csvHeader = []string{"record", "date", "time", "id", "client", "table", "error", "revenue", "occupied_minutes", "band", "category", "end", "billed_minutes", "queued_in", "reason", "visits", "table_changes", "waited_minutes", "ejected", "details", "opening", "top_ups", "charges", "closing", "gross", "discount", "package", "paused_minutes"}
+
+type csvRow struct {
+	record, date, time, id, client, table, err, revenue, occupied, band, category string
+	end, billed, queuedIn, reason                                                 string
+	visits, tableChanges, waited, ejected, details                                string
+	opening, topUps, charges, closing, gross, discount, pkg, paused               string
+}
+
+func (r csvRow) fields() []string {
+	return []string{r.record, r.date, r.time, r.id, r.client, r.table, r.err, r.revenue, r.occupied, r.band, r.category, r.end, r.billed, r.queuedIn, r.reason, r.visits, r.tableChanges, r.waited, r.ejected, r.details, r.opening, r.topUps, r.charges, r.closing, r.gross, r.discount, r.pkg, r.paused}
+}
+
+func (cw *CSVWriter) Write(w io.Writer, r *Report) error {
+	var rows []csvRow
+
+	for _, d := range r.Days {
+		date := ""
+		if d.Dated() {
+			date = d.Date.Format(club.DateFormat)
+		}
+
+		rows = append(rows, csvRow{record: "open", date: date, time: d.Open.Format(club.TimeFormat)})
+
+		for _, e := range d.Events {
+			record := "event"
+			if e.Generated {
+				record = "generated"
+			}
+			rows = append(rows, csvRow{
+				record:  record,
+				date:    date,
+				time:    e.Time.Format(club.TimeFormat),
+				id:      strconv.Itoa(e.ID),
+				client:  e.Client,
+				table:   optionalInt(e.TableID),
+				err:     e.Error,
+				details: e.Details,
+			})
+		}
+
+		rows = append(rows, csvRow{record: "close", date: date, time: d.Close.Format(club.TimeFormat)})
+
+		for _, s := range d.Sessions {
+			rows = append(rows, csvRow{
+				record:   "session",
+				date:     date,
+				time:     s.Start.Format(club.TimeFormat),
+				client:   s.Client,
+				table:    strconv.Itoa(s.TableID),
+				revenue:  strconv.Itoa(s.Amount),
+				gross:    strconv.Itoa(s.Gross()),
+				discount: strconv.Itoa(s.Discount),
+				pkg:      s.Package,
+				paused:   strconv.Itoa(int(s.Paused.Minutes())),
+				occupied: strconv.Itoa(int(s.Duration().Minutes())),
+				end:      s.End.Format(club.TimeFormat),
+				billed:   strconv.Itoa(int(s.Billed.Minutes())),
+				queuedIn: strconv.FormatBool(s.QueuedIn),
+				reason:   string(s.Reason),
+			})
+		}
+
+		if r.MultiDay() {
+			rows = append(rows, csvSummaryRows(date, d.Tables, d.Categories)...)
+			rows = append(rows, csvClientRows(date, d.Clients)...)
+		}
+
+		for _, l := range d.Ledger {
+			rows = append(rows, csvRow{
+				record:  "ledger",
+				date:    date,
+				client:  l.Client,
+				opening: strconv.Itoa(l.Opening),
+				topUps:  strconv.Itoa(l.TopUps),
+				charges: strconv.Itoa(l.Charges),
+				closing: strconv.Itoa(l.Closing),
+			})
+		}
+	}
+
+	rows = append(rows, csvSummaryRows("", r.Tables, r.Categories)...)
+	rows = append(rows, csvClientRows("", r.Clients)...)
+
+	cvw := csv.NewWriter(w)
+	if err := cvw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := cvw.Write(row.fields()); err != nil {
+			return err
+		}
+	}
+
+	cvw.Flush()
+	return cvw.Error()
+}
+
+func csvSummaryRows(date string, tables []TableSummary, categories []CategorySummary) []csvRow {
+	var rows []csvRow
+
+	for _, t := range tables {
+		rows = append(rows, csvRow{
+			record:   "table",
+			date:     date,
+			table:    strconv.Itoa(t.TableID),
+			revenue:  strconv.Itoa(t.Revenue),
+			gross:    strconv.Itoa(t.Gross()),
+			discount: strconv.Itoa(t.Discount),
+			occupied: strconv.Itoa(int(t.Occupied.Minutes())),
+			category: t.Category,
+		})
+
+		for _, b := range t.Bands {
+			rows = append(rows, csvRow{
+				record:   "band",
+				date:     date,
+				table:    strconv.Itoa(t.TableID),
+				revenue:  strconv.Itoa(b.Revenue),
+				band:     b.Name,
+				category: t.Category,
+			})
+		}
+	}
+
+	for _, c := range categories {
+		rows = append(rows, csvRow{
+			record:   "category",
+			date:     date,
+			revenue:  strconv.Itoa(c.Revenue),
+			occupied: strconv.Itoa(int(c.Occupied.Minutes())),
+			category: c.Name,
+		})
+	}
+
+	return rows
+}
+
+func csvClientRows(date string, clients []ClientSummary) []csvRow {
+	var rows []csvRow
+
+	for _, c := range clients {
+		rows = append(rows, csvRow{
+			record:       "client",
+			date:         date,
+			client:       c.Client,
+			revenue:      strconv.Itoa(c.Amount),
+			occupied:     strconv.Itoa(int(c.Played.Minutes())),
+			visits:       strconv.Itoa(c.Visits),
+			tableChanges: strconv.Itoa(c.TableChanges),
+			waited:       strconv.Itoa(int(c.Waited.Minutes())),
+			ejected:      strconv.FormatBool(c.Ejected),
+		})
+	}
+
+	return rows
+}
+
+func optionalInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
@@ -0,0 +1,268 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/table"
+)
+
+func at(day time.Time, hour, minute int) time.Time {
+	return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+var undated = time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func singleDayReport() *Report {
+	tables := []TableSummary{
+		{TableID: 1, Revenue: 80, Discount: 20, Occupied: 9*time.Hour + 50*time.Minute},
+		{TableID: 2},
+	}
+	clients := []ClientSummary{
+		{Client: "client1", Visits: 1, Played: 9*time.Hour + 50*time.Minute, Amount: 80, Ejected: true},
+		{Client: "client2", Visits: 1},
+	}
+
+	day := &Day{
+		Date:  undated,
+		Open:  at(undated, 9, 0),
+		Close: at(undated, 19, 0),
+		Events: []Event{
+			{Time: at(undated, 9, 10), ID: 1, Client: "client1"},
+			{Time: at(undated, 9, 10), ID: 2, Client: "client1", TableID: 1},
+			{Time: at(undated, 9, 20), ID: 1, Client: "client2"},
+			{Time: at(undated, 9, 21), ID: 3, Client: "client2"},
+			{Time: at(undated, 9, 21), ID: 13, Error: "ICanWaitNoLonger!", Generated: true},
+			{Time: at(undated, 19, 0), ID: 11, Client: "client1", Generated: true},
+		},
+		Sessions: []table.Session{{
+			Client:   "client1",
+			TableID:  1,
+			Start:    at(undated, 9, 10),
+			End:      at(undated, 19, 0),
+			Billed:   10 * time.Hour,
+			Amount:   80,
+			Discount: 20,
+			Reason:   table.SessionClosed,
+		}},
+		Tables:  tables,
+		Clients: clients,
+		Ledger:  []LedgerEntry{{Client: "client1", Opening: 100, Charges: 80, Closing: 20}},
+	}
+
+	return &Report{Days: []*Day{day}, Tables: tables, Clients: clients}
+}
+
+func multiDayReport() *Report {
+	first := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+
+	return &Report{
+		Days: []*Day{
+			{
+				Date:  first,
+				Open:  at(first, 9, 0),
+				Close: at(first, 19, 0),
+				Events: []Event{
+					{Time: at(first, 9, 10), ID: 1, Client: "client1"},
+					{Time: at(first, 9, 10), ID: 2, Client: "client1", TableID: 1},
+					{Time: at(first, 10, 10), ID: 4, Client: "client1"},
+				},
+				Sessions: []table.Session{{Client: "client1", TableID: 1, Start: at(first, 9, 10), End: at(first, 10, 10), Billed: time.Hour, Amount: 10, Reason: table.SessionLeft}},
+				Tables:   []TableSummary{{TableID: 1, Revenue: 10, Occupied: time.Hour}},
+				Clients:  []ClientSummary{{Client: "client1", Visits: 1, Played: time.Hour, Amount: 10}},
+			},
+			{
+				Date:   second,
+				Open:   at(second, 9, 0),
+				Close:  at(second, 19, 0),
+				Tables: []TableSummary{{TableID: 1}},
+			},
+		},
+		Tables:  []TableSummary{{TableID: 1, Revenue: 10, Occupied: time.Hour}},
+		Clients: []ClientSummary{{Client: "client1", Visits: 1, Played: time.Hour, Amount: 10}},
+	}
+}
+
+func TestTextWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  *Report
+		clients bool
+		want    string
+	}{
+		{
+			name:    "single day",
+			report:  singleDayReport(),
+			clients: true,
+			want: `09:00
+09:10 1 client1
+09:10 2 client1 1
+09:20 1 client2
+09:21 3 client2
+09:21 13 ICanWaitNoLonger!
+19:00 11 client1
+19:00
+1 80 09:50 gross:100 discount:20
+2 0 00:00
+client1 80 09:50 0 00:00 ejected
+client2 0 00:00 0 00:00
+client1 100 +0 -80 20
+`,
+		},
+		{
+			name:   "several days",
+			report: multiDayReport(),
+			want: `2026-10-18
+09:00
+09:10 1 client1
+09:10 2 client1 1
+10:10 4 client1
+19:00
+1 10 01:00
+2026-10-19
+09:00
+19:00
+1 0 00:00
+total
+1 10 01:00
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := (&TextWriter{Clients: tt.clients}).Write(&sb, tt.report); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+
+			if sb.String() != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, sb.String())
+			}
+		})
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var out struct {
+		Days []struct {
+			Date     *string           `json:"date"`
+			Events   []json.RawMessage `json:"events"`
+			Sessions []jsonSession     `json:"sessions"`
+			Tables   []jsonTable       `json:"tables"`
+			Ledger   []jsonLedgerEntry `json:"ledger"`
+		} `json:"days"`
+		Tables  []jsonTable  `json:"tables"`
+		Clients []jsonClient `json:"clients"`
+	}
+
+	decode := func(r *Report) {
+		t.Helper()
+
+		var sb strings.Builder
+		if err := NewWriter(FormatJSON).Write(&sb, r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		out.Days = nil
+		if err := json.Unmarshal([]byte(sb.String()), &out); err != nil {
+			t.Fatalf("failed to decode %s: %v", sb.String(), err)
+		}
+	}
+
+	decode(singleDayReport())
+	if len(out.Days) != 1 || out.Days[0].Date != nil || len(out.Days[0].Events) != 6 {
+		t.Fatalf("expected one undated day with 6 events, got %+v", out.Days)
+	}
+
+	want := jsonSession{Client: "client1", TableID: 1, Start: "09:10", End: "19:00", Duration: "09:50", BilledMinutes: 600, Amount: 80, Gross: 100, Discount: 20, Reason: "closed"}
+	if s := out.Days[0].Sessions; len(s) != 1 || s[0] != want {
+		t.Errorf("expected session %+v, got %+v", want, s)
+	}
+	if l := out.Days[0].Ledger; len(l) != 1 || l[0].Closing != 20 || l[0].Charges != 80 {
+		t.Errorf("unexpected ledger %+v", l)
+	}
+	if len(out.Clients) != 2 || !out.Clients[0].Ejected || out.Clients[0].PlayedMinutes != 590 {
+		t.Errorf("unexpected clients %+v", out.Clients)
+	}
+
+	decode(multiDayReport())
+	if len(out.Days) != 2 || out.Days[0].Date == nil || *out.Days[0].Date != "2026-10-18" || *out.Days[1].Date != "2026-10-19" {
+		t.Fatalf("expected two dated days, got %+v", out.Days)
+	}
+	if out.Days[1].Events == nil || len(out.Days[1].Events) != 0 || out.Days[1].Sessions == nil {
+		t.Errorf("expected empty lists for a day without events, got %+v", out.Days[1])
+	}
+	if len(out.Tables) != 1 || out.Tables[0].Revenue != 10 || out.Tables[0].OccupiedMinutes != 60 {
+		t.Errorf("unexpected totals %+v", out.Tables)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	if len(csvHeader) != len(csvRow{}.fields()) {
+		t.Fatalf("header has %d columns, rows have %d", len(csvHeader), len(csvRow{}.fields()))
+	}
+
+	read := func(r *Report) []map[string]string {
+		t.Helper()
+
+		var sb strings.Builder
+		if err := NewWriter(FormatCSV).Write(&sb, r); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+
+		records, err := csv.NewReader(strings.NewReader(sb.String())).ReadAll()
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", sb.String(), err)
+		}
+
+		var rows []map[string]string
+		for _, record := range records[1:] {
+			row := make(map[string]string)
+			for i, name := range records[0] {
+				row[name] = record[i]
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	kinds := func(rows []map[string]string) string {
+		var names []string
+		for _, row := range rows {
+			names = append(names, row["record"]+"/"+row["date"])
+		}
+		return strings.Join(names, ",")
+	}
+
+	rows := read(singleDayReport())
+	want := "open/,event/,event/,event/,event/,generated/,generated/,close/,session/,ledger/,table/,table/,client/,client/"
+	if got := kinds(rows); got != want {
+		t.Fatalf("expected rows %s, got %s", want, got)
+	}
+
+	session := rows[8]
+	if session["revenue"] != "80" || session["gross"] != "100" || session["discount"] != "20" || session["billed_minutes"] != "600" || session["occupied_minutes"] != "590" || session["reason"] != "closed" {
+		t.Errorf("unexpected session row %v", session)
+	}
+	if ledger := rows[9]; ledger["opening"] != "100" || ledger["charges"] != "80" || ledger["closing"] != "20" {
+		t.Errorf("unexpected ledger row %v", ledger)
+	}
+	if generated := rows[5]; generated["id"] != "13" || generated["error"] != "ICanWaitNoLonger!" || generated["table"] != "" {
+		t.Errorf("unexpected error row %v", generated)
+	}
+
+	rows = read(multiDayReport())
+	want = "open/2026-10-18,event/2026-10-18,event/2026-10-18,event/2026-10-18,close/2026-10-18,session/2026-10-18,table/2026-10-18,client/2026-10-18," +
+		"open/2026-10-19,close/2026-10-19,table/2026-10-19," +
+		"table/,client/"
+	if got := kinds(rows); got != want {
+		t.Fatalf("expected rows %s, got %s", want, got)
+	}
+	if total := rows[11]; total["revenue"] != "10" || total["occupied_minutes"] != "60" {
+		t.Errorf("unexpected total row %v", total)
+	}
+}
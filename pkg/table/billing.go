@@ -0,0 +1,83 @@
+package table
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrUnknownBilling = errors.New("UnknownBillingStrategy")
+
+const (
+	BillingHourly    = "hourly"
+	BillingPerMinute = "per_minute"
+	BillingBlock     = "block"
+	BillingFirstHour = "first_hour"
+)
+
+// BillingStrategy turns the length of a session into the time charged for
+// it. The charged time is then priced by the Tariff of the club.
+type BillingStrategy interface {
+	Billed(d time.Duration) time.Duration
+}
+
+// BlockBilling charges for every started block of time. A remainder no
+// longer than Grace is not charged.
+type BlockBilling struct {
+	Block time.Duration
+	Grace time.Duration
+}
+
+func (b BlockBilling) Billed(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	blocks := d / b.Block
+	if d%b.Block > b.Grace {
+		blocks++
+	}
+	return blocks * b.Block
+}
+
+type PerMinuteBilling struct{}
+
+func (p PerMinuteBilling) Billed(d time.Duration) time.Duration {
+	return BlockBilling{Block: time.Minute}.Billed(d)
+}
+
+// FirstHourBilling charges a full hour for the beginning of the session and
+// every started minute after it.
+type FirstHourBilling struct{}
+
+func (f FirstHourBilling) Billed(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	if d <= time.Hour {
+		return time.Hour
+	}
+	return time.Hour + PerMinuteBilling{}.Billed(d-time.Hour)
+}
+
+func NewBillingStrategy(name string, block, grace time.Duration) (BillingStrategy, error) {
+	switch strings.ToLower(name) {
+	case "", BillingHourly:
+		return BlockBilling{Block: time.Hour, Grace: grace}, nil
+	case BillingPerMinute:
+		return PerMinuteBilling{}, nil
+	case BillingBlock:
+		if block <= 0 {
+			return nil, ErrUnknownBilling
+		}
+		return BlockBilling{Block: block, Grace: grace}, nil
+	case BillingFirstHour:
+		return FirstHourBilling{}, nil
+	}
+	return nil, ErrUnknownBilling
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,164 @@
+package table
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+func TestBillingStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy BillingStrategy
+		duration time.Duration
+		expected time.Duration
+	}{
+		{"hourly rounds up", BlockBilling{Block: time.Hour}, 61 * time.Minute, 2 * time.Hour},
+		{"hourly exact", BlockBilling{Block: time.Hour}, 2 * time.Hour, 2 * time.Hour},
+		{"hourly grace", BlockBilling{Block: time.Hour, Grace: 5 * time.Minute}, 65 * time.Minute, time.Hour},
+		{"per minute", PerMinuteBilling{}, 90*time.Minute + time.Second, 91 * time.Minute},
+		{"quarter blocks", BlockBilling{Block: 15 * time.Minute}, 50 * time.Minute, time.Hour},
+		{"half hour blocks", BlockBilling{Block: 30 * time.Minute}, 50 * time.Minute, time.Hour},
+		{"first hour short", FirstHourBilling{}, 10 * time.Minute, time.Hour},
+		{"first hour then minutes", FirstHourBilling{}, 72 * time.Minute, 72 * time.Minute},
+		{"empty session", FirstHourBilling{}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.strategy.Billed(tt.duration); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func playedFrom(start time.Time, d time.Duration) Intervals {
+	return Intervals{{Start: start, End: start.Add(d)}}
+}
+
+func TestTariffAmount(t *testing.T) {
+	from, _ := time.Parse(club.TimeFormat, "18:00")
+	to, _ := time.Parse(club.TimeFormat, "22:00")
+	tariff := NewTariff([]club.TariffBand{{Name: "peak", TimeWindow: club.TimeWindow{From: from, To: to}, Price: 20}})
+
+	start, _ := time.Parse(club.TimeFormat, "17:30")
+	total, byBand := tariff.Amount(playedFrom(start, time.Hour), time.Hour, 10, 10)
+
+	if total != 15 || byBand[BaseBand] != 5 || byBand["peak"] != 10 {
+		t.Errorf("Expected 5 base and 10 peak, got %d total and %v", total, byBand)
+	}
+
+	total, byBand = tariff.Amount(playedFrom(start, time.Hour), time.Hour, 10, 20)
+
+	if total != 30 || byBand[BaseBand] != 10 || byBand["peak"] != 20 {
+		t.Errorf("Expected 10 base and 20 peak for a double priced table, got %d total and %v", total, byBand)
+	}
+
+	// 16:30-17:50 is billed as two hours, the rounding is priced as the last minute played.
+	start, _ = time.Parse(club.TimeFormat, "16:30")
+	total, byBand = tariff.Amount(playedFrom(start, 80*time.Minute), 2*time.Hour, 10, 10)
+
+	if total != 20 || byBand[BaseBand] != 20 || byBand["peak"] != 0 {
+		t.Errorf("Expected no peak charged after the session, got %d total and %v", total, byBand)
+	}
+
+	// 17:10-18:10 is billed as an hour with a grace period, the forgiven minutes are the last ones.
+	start, _ = time.Parse(club.TimeFormat, "17:10")
+	total, byBand = tariff.Amount(playedFrom(start, 70*time.Minute), time.Hour, 10, 10)
+
+	if total != 13 || byBand[BaseBand] != 9 || byBand["peak"] != 4 {
+		t.Errorf("Expected 50 base and 10 peak minutes, got %d total and %v", total, byBand)
+	}
+}
+
+func TestDiscountsAmount(t *testing.T) {
+	from, _ := time.Parse(club.TimeFormat, "12:00")
+	to, _ := time.Parse(club.TimeFormat, "14:00")
+	discounts := NewDiscounts(&club.Discounts{
+		HappyHours: []club.HappyHour{{Name: "lunch", TimeWindow: club.TimeWindow{From: from, To: to}, Percent: 50}},
+		Bundles:    []club.Bundle{{Name: "three-for-two", Hours: 3, Paid: 2}},
+	})
+
+	start, _ := time.Parse(club.TimeFormat, "09:00")
+	if discount := discounts.Amount(playedFrom(start, 3*time.Hour), 3*time.Hour, 30, 20); discount != 14 {
+		t.Errorf("Expected a free hour and 20%% off the rest, got %d", discount)
+	}
+
+	start, _ = time.Parse(club.TimeFormat, "10:00")
+	if discount := discounts.Amount(playedFrom(start, 4*time.Hour), 4*time.Hour, 40, 30); discount != 23 {
+		t.Errorf("Expected a free hour, half off the lunch and 30%% off the rest, got %d", discount)
+	}
+
+	if discount := NewDiscounts(nil).Amount(playedFrom(start, time.Hour), time.Hour, 10, 0); discount != 0 {
+		t.Errorf("Expected no discount without discounts, got %d", discount)
+	}
+}
+
+func TestPackageCharge(t *testing.T) {
+	clock := func(value string) time.Time {
+		v, _ := time.Parse(club.TimeFormat, value)
+		return v
+	}
+	night := &club.TimePackage{Name: "night", TimeWindow: club.TimeWindow{From: clock("22:00"), To: clock("08:00")}, Price: 50}
+
+	repo := NewMemoryRepo(2, nil, nil, nil, nil)
+	if err := repo.TakeUpTable("anna", 1, clock("21:00")); err != nil {
+		t.Fatalf("TakeUpTable: %v", err)
+	}
+	if err := repo.SetClientPackage("anna", night); err != nil {
+		t.Fatalf("SetClientPackage: %v", err)
+	}
+	if err := repo.SetClientPackage("anna", night); err != ErrPackageAssigned {
+		t.Errorf("Expected ErrPackageAssigned, got %v", err)
+	}
+
+	repo.TakeDownTable("anna")
+	session, _ := repo.UpdateRevenue(1, 10, clock("23:00"))
+	if session.Amount != 60 || session.Package != "night" {
+		t.Errorf("Expected an hour before the window and the package, got %+v", session)
+	}
+
+	if err := repo.TakeUpTable("anna", 2, clock("23:00")); err != nil {
+		t.Fatalf("TakeUpTable: %v", err)
+	}
+	repo.TakeDownTable("anna")
+
+	// The next morning, an hour after the window.
+	session, _ = repo.UpdateRevenue(2, 10, clock("23:00").Add(10*time.Hour))
+	if session.Amount != 10 || session.Billed != 10*time.Hour {
+		t.Errorf("Expected the package collected once and an hour of overflow, got %+v", session)
+	}
+}
+
+func TestPausedCharge(t *testing.T) {
+	clock := func(value string) time.Time {
+		v, _ := time.Parse(club.TimeFormat, value)
+		return v
+	}
+	peak := club.TariffBand{Name: "peak", TimeWindow: club.TimeWindow{From: clock("18:00"), To: clock("22:00")}, Price: 20}
+
+	repo := NewMemoryRepo(1, nil, NewTariff([]club.TariffBand{peak}), nil, nil)
+	if err := repo.TakeUpTable("anna", 1, clock("16:00")); err != nil {
+		t.Fatalf("TakeUpTable: %v", err)
+	}
+	if _, err := repo.Pause("anna", clock("17:00")); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if _, err := repo.Resume("anna", clock("19:00")); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+
+	played := repo.GetAll()[1].PlayedUntil(clock("20:00"))
+	if len(played) != 2 || !played[1].Start.Equal(clock("19:00")) || played.Duration() != 2*time.Hour {
+		t.Fatalf("expected an hour before and an hour after the break, got %+v", played)
+	}
+
+	repo.TakeDownTable("anna")
+	session, _ := repo.UpdateRevenue(1, 10, clock("20:00"))
+	if session.Amount != 30 || session.Billed != 2*time.Hour || session.Paused != 2*time.Hour {
+		t.Errorf("expected a base hour and a peak hour, got %+v", session)
+	}
+	if bands := repo.GetAll()[1].BandRevenue; bands[BaseBand] != 10 || bands["peak"] != 20 {
+		t.Errorf("expected 10 base and 20 peak, got %v", bands)
+	}
+}
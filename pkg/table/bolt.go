@@ -0,0 +1,232 @@
+package table
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+var (
+	tablesBucket       = []byte("tables")
+	reservationsBucket = []byte("reservations")
+)
+
+// TableRepositoryBolt keeps the tables and reservations in memory and writes
+// every change through to a bbolt database, so open sessions, bookings and
+// the revenue collected so far survive a restart. Client discounts and
+// packages not yet taken to a table are kept in memory only.
+type TableRepositoryBolt struct {
+	*TableRepositoryMemory
+	db *bolt.DB
+}
+
+func (r *TableRepositoryBolt) TakeUpTable(clientName string, tableID int, t time.Time) error {
+	if err := r.TableRepositoryMemory.TakeUpTable(clientName, tableID, t); err != nil {
+		return err
+	}
+
+	if err := r.put(tableID); err != nil {
+		return err
+	}
+	return r.putReservations()
+}
+
+func (r *TableRepositoryBolt) Reserve(reservation *club.Reservation) error {
+	if err := r.TableRepositoryMemory.Reserve(reservation); err != nil {
+		return err
+	}
+	return r.putReservations()
+}
+
+func (r *TableRepositoryBolt) CancelReservation(clientName string) (*club.Reservation, error) {
+	reservation, err := r.TableRepositoryMemory.CancelReservation(clientName)
+	if err != nil {
+		return nil, err
+	}
+	return reservation, r.putReservations()
+}
+
+// MoveReservation saves the booking also when it stays at the table, as it
+// is then kept for the client who came.
+func (r *TableRepositoryBolt) MoveReservation(clientName string, tableID int, t time.Time) (int, error) {
+	movedID, err := r.TableRepositoryMemory.MoveReservation(clientName, tableID, t)
+	if errors.Is(err, ErrReservationNotFound) {
+		return movedID, err
+	}
+
+	if putErr := r.putReservations(); putErr != nil {
+		return movedID, putErr
+	}
+	return movedID, err
+}
+
+// ReleaseExpired cannot report a failed write; the reservations are saved
+// in full again by the next change.
+func (r *TableRepositoryBolt) ReleaseExpired(t time.Time) []*club.Reservation {
+	r.mu.RLock()
+	held := len(r.reservations)
+	r.mu.RUnlock()
+
+	released := r.TableRepositoryMemory.ReleaseExpired(t)
+
+	r.mu.RLock()
+	changed := len(r.reservations) != held
+	r.mu.RUnlock()
+
+	if changed {
+		_ = r.putReservations()
+	}
+	return released
+}
+
+func (r *TableRepositoryBolt) TakeDownTable(clientName string) (int, error) {
+	tableID, err := r.TableRepositoryMemory.TakeDownTable(clientName)
+	if err != nil || tableID == 0 {
+		return tableID, err
+	}
+	return tableID, r.put(tableID)
+}
+
+func (r *TableRepositoryBolt) UpdateRevenue(tableID, price int, t time.Time) (Session, error) {
+	session, err := r.TableRepositoryMemory.UpdateRevenue(tableID, price, t)
+	if err != nil {
+		return session, err
+	}
+	return session, r.put(tableID)
+}
+
+func (r *TableRepositoryBolt) Pause(clientName string, t time.Time) (int, error) {
+	tableID, err := r.TableRepositoryMemory.Pause(clientName, t)
+	if err != nil {
+		return tableID, err
+	}
+	return tableID, r.put(tableID)
+}
+
+func (r *TableRepositoryBolt) Resume(clientName string, t time.Time) (int, error) {
+	tableID, err := r.TableRepositoryMemory.Resume(clientName, t)
+	if err != nil {
+		return tableID, err
+	}
+	return tableID, r.put(tableID)
+}
+
+// SetClientPackage saves the table of the client, as the package covers
+// the session in progress too.
+func (r *TableRepositoryBolt) SetClientPackage(clientName string, p *club.TimePackage) error {
+	if err := r.TableRepositoryMemory.SetClientPackage(clientName, p); err != nil {
+		return err
+	}
+
+	if tableID, ok := r.Exists(clientName); ok {
+		return r.put(tableID)
+	}
+	return nil
+}
+
+// putReservations replaces the stored reservations with the ones held now.
+func (r *TableRepositoryBolt) putReservations() error {
+	r.mu.RLock()
+	data := make(map[string][]byte, len(r.reservations))
+	for _, reservation := range r.reservations {
+		encoded, err := json.Marshal(reservation)
+		if err != nil {
+			r.mu.RUnlock()
+			return err
+		}
+		data[reservation.Client] = encoded
+	}
+	r.mu.RUnlock()
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(reservationsBucket); err != nil {
+			return err
+		}
+
+		bucket, err := tx.CreateBucket(reservationsBucket)
+		if err != nil {
+			return err
+		}
+
+		for client, encoded := range data {
+			if err := bucket.Put([]byte(client), encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *TableRepositoryBolt) put(tableID int) error {
+	r.mu.RLock()
+	data, err := json.Marshal(r.tables[tableID])
+	r.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tablesBucket).Put([]byte(strconv.Itoa(tableID)), data)
+	})
+}
+
+// NewBoltRepo opens the tables and reservations stored in db, creating the
+// buckets on first use. Tables taken before the restart keep their client
+// and start time.
+func NewBoltRepo(db *bolt.DB, maxTables int, billing BillingStrategy, tariff *Tariff, discounts *Discounts, categories []club.TableCategory) (*TableRepositoryBolt, error) {
+	r := &TableRepositoryBolt{
+		TableRepositoryMemory: NewMemoryRepo(maxTables, billing, tariff, discounts, categories),
+		db:                    db,
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(tablesBucket)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.ForEach(func(_, data []byte) error {
+			table := &Table{}
+			if err := json.Unmarshal(data, table); err != nil {
+				return err
+			}
+
+			if table.BandRevenue == nil {
+				table.BandRevenue = make(map[string]int)
+			}
+			r.tables[table.TableID] = table
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		bucket, err = tx.CreateBucketIfNotExists(reservationsBucket)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(_, data []byte) error {
+			reservation := &club.Reservation{}
+			if err := json.Unmarshal(data, reservation); err != nil {
+				return err
+			}
+			r.reservations = append(r.reservations, reservation)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(r.reservations, func(i, j int) bool {
+		return r.reservations[i].Start.Before(r.reservations[j].Start)
+	})
+	return r, nil
+}
@@ -0,0 +1,73 @@
+package table
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+func TestBoltRepoRecoversSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "club.db")
+	start := time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	repo, err := NewBoltRepo(db, 2, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewBoltRepo: %v", err)
+	}
+
+	if err := repo.TakeUpTable("client1", 1, start); err != nil {
+		t.Fatalf("TakeUpTable: %v", err)
+	}
+	if err := repo.TakeUpTable("client2", 2, start); err != nil {
+		t.Fatalf("TakeUpTable: %v", err)
+	}
+	repo.TakeDownTable("client2")
+	if _, err := repo.UpdateRevenue(2, 10, start.Add(90*time.Minute)); err != nil {
+		t.Fatalf("UpdateRevenue: %v", err)
+	}
+	if err := repo.Reserve(&club.Reservation{Client: "client3", TableID: 2, Start: start.Add(3 * time.Hour), Duration: time.Hour}); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := repo.Reserve(&club.Reservation{Client: "client4", TableID: 3, Start: start, Duration: time.Hour}); !errors.Is(err, ErrTableOutOfRange) {
+		t.Errorf("expected %v for a table out of range, got %v", ErrTableOutOfRange, err)
+	}
+	db.Close()
+
+	db, err = bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer db.Close()
+
+	repo, err = NewBoltRepo(db, 2, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewBoltRepo: %v", err)
+	}
+
+	if tableID, ok := repo.Exists("client1"); !ok || tableID != 1 {
+		t.Errorf("expected client1 to still sit at table 1, got %d", tableID)
+	}
+
+	tables := repo.GetAll()
+	if !tables[1].StartTime.Equal(start) {
+		t.Errorf("expected the session to start at %v, got %v", start, tables[1].StartTime)
+	}
+
+	if tables[2].ClientName != "" || tables[2].Revenue != 20 || tables[2].AllTime != 90*time.Minute {
+		t.Errorf("unexpected state of table 2: %+v", tables[2])
+	}
+
+	if err := repo.TakeUpTable("client2", 2, start.Add(3*time.Hour)); !errors.Is(err, ErrTableReserved) {
+		t.Errorf("expected the reservation of table 2 to survive the restart, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package table
+
+import (
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+// Discounts takes the discounts of the club off the price of a session, in
+// order: the best bundle makes some of the charged hours free, happy hours
+// take their percentage off the time charged within them, and the
+// percentage of the client is taken off what is left.
+type Discounts struct {
+	happyHours []club.HappyHour
+	bundles    []club.Bundle
+}
+
+// Amount returns the discount on the gross price of the time charged for a
+// session played in the given intervals for a client with the given
+// percentage.
+func (d *Discounts) Amount(played Intervals, billed time.Duration, gross, percent int) int {
+	minutes := int(billed / time.Minute)
+	if gross <= 0 || minutes == 0 {
+		return 0
+	}
+
+	discount := 0
+	for _, b := range d.bundles {
+		free := int(billed/(time.Duration(b.Hours)*time.Hour)) * (b.Hours - b.Paid) * 60
+		if amount := gross * free / minutes; amount > discount {
+			discount = amount
+		}
+	}
+
+	happy := 0
+	for i := 0; i < minutes; i++ {
+		happy += d.happyPercentAt(played.minute(i))
+	}
+	discount += (gross - discount) * happy / (minutes * 100)
+
+	discount += (gross - discount) * percent / 100
+	return discount
+}
+
+func (d *Discounts) happyPercentAt(t time.Time) int {
+	for _, h := range d.happyHours {
+		if h.Contains(t) {
+			return h.Percent
+		}
+	}
+	return 0
+}
+
+func NewDiscounts(discounts *club.Discounts) *Discounts {
+	if discounts == nil {
+		return &Discounts{}
+	}
+
+	return &Discounts{
+		happyHours: discounts.HappyHours,
+		bundles:    discounts.Bundles,
+	}
+}
@@ -0,0 +1,39 @@
+package table
+
+import (
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+// Package is the time package bought by the client of a session. Its price
+// is collected once, by the first session that has time in its window.
+type Package struct {
+	club.TimePackage
+	Charged bool
+}
+
+// overflow splits the time played between the window of the package and
+// the rest. It returns the time inside the window and the intervals played
+// outside it; the last part of a minute counts as outside.
+func (p *Package) overflow(played Intervals) (time.Duration, Intervals) {
+	inside := time.Duration(0)
+	outside := Intervals{}
+
+	d := played.Duration()
+	minutes := int(d / time.Minute)
+	for i := 0; i < minutes; i++ {
+		minute := played.at(time.Duration(i) * time.Minute)
+		if p.Contains(minute) {
+			inside += time.Minute
+		} else {
+			outside = outside.add(Interval{Start: minute, End: minute.Add(time.Minute)})
+		}
+	}
+
+	if rest := d - time.Duration(minutes)*time.Minute; rest > 0 {
+		last := played.at(time.Duration(minutes) * time.Minute)
+		outside = outside.add(Interval{Start: last, End: last.Add(rest)})
+	}
+	return inside, outside
+}
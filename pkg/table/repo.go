@@ -2,30 +2,60 @@ package table
 
 import (
 	"errors"
-	"math"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
 )
 
 var (
-	ErrPlaceIsBusy   = errors.New("PlaceIsBusy")
-	ErrTableNotFound = errors.New("TableNotFound")
-	ErrTablesFull    = errors.New("TablesFull")
+	ErrPlaceIsBusy         = errors.New("PlaceIsBusy")
+	ErrTableNotFound       = errors.New("TableNotFound")
+	ErrTablesFull          = errors.New("TablesFull")
+	ErrTableOutOfRange     = errors.New("TableOutOfRange")
+	ErrTableReserved       = errors.New("TableReserved")
+	ErrReservationConflict = errors.New("ReservationConflict")
+	ErrReservationNotFound = errors.New("ReservationUnknown")
+	ErrCategoryNotFound    = errors.New("CategoryUnknown")
+	ErrPackageAssigned     = errors.New("PackageAlreadyBought")
+	ErrNotAtTable          = errors.New("ClientNotAtTable")
+	ErrAlreadyPaused       = errors.New("AlreadyPaused")
+	ErrNotPaused           = errors.New("NotPaused")
 )
 
 type TableRepository interface {
 	GetAll() map[int]*Table
 	TakeUpTable(clientName string, tableID int, t time.Time) error
-	TakeDownTable(clientName string) int
-	UpdateRevenue(tableID, price int, t time.Time) error
+	TakeDownTable(clientName string) (int, error)
+	UpdateRevenue(tableID, price int, t time.Time) (Session, error)
 	Exists(clientName string) (int, bool)
 	CountEmptyTables() int
+	Reserve(reservation *club.Reservation) error
+	CancelReservation(clientName string) (*club.Reservation, error)
+	MoveReservation(clientName string, tableID int, t time.Time) (int, error)
+	ReleaseExpired(t time.Time) []*club.Reservation
+	RunsOut(tableID, price, balance int, from, t time.Time) (time.Time, bool)
+	Due(table *Table, price int, t time.Time) int
+	SetClientDiscount(clientName string, percent int)
+	SetClientPackage(clientName string, p *club.TimePackage) error
+	Pause(clientName string, t time.Time) (int, error)
+	Resume(clientName string, t time.Time) (int, error)
+	PausedLonger(limit time.Duration, t time.Time) []int
 }
 
 type TableRepositoryMemory struct {
-	tables    map[int]*Table
-	maxTables int
-	mu        *sync.RWMutex
+	tables     map[int]*Table
+	maxTables  int
+	billing    BillingStrategy
+	tariff     *Tariff
+	discounts  *Discounts
+	categories []club.TableCategory
+
+	reservations []*club.Reservation
+	percents     map[string]int
+	packages     map[string]*Package
+	mu           *sync.RWMutex
 }
 
 func (r *TableRepositoryMemory) GetAll() map[int]*Table {
@@ -45,6 +75,9 @@ func (r *TableRepositoryMemory) TakeUpTable(clientName string, tableID int, t ti
 	table, ok := r.tables[tableID]
 	if !ok {
 		table = NewTable("", tableID, t)
+		category := club.FindCategory(r.categories, tableID)
+		table.Category = category.Name
+		table.Price = category.Price
 		r.tables[tableID] = table
 	}
 
@@ -52,41 +85,258 @@ func (r *TableRepositoryMemory) TakeUpTable(clientName string, tableID int, t ti
 		return ErrPlaceIsBusy
 	}
 
+	if err := r.claimReservation(clientName, tableID, t); err != nil {
+		return err
+	}
+
 	table.ClientName = clientName
 	table.StartTime = t
+	table.Percent = r.percents[clientName]
+	table.Package = r.packages[clientName]
+	table.Pauses = nil
 	return nil
 }
 
-func (r *TableRepositoryMemory) TakeDownTable(clientName string) int {
+func (r *TableRepositoryMemory) TakeDownTable(clientName string) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	for tableID, table := range r.tables {
 		if table.ClientName == clientName {
 			r.tables[tableID].ClientName = ""
-			return tableID
+			return tableID, nil
 		}
 	}
 
-	return 0
+	return 0, nil
 }
 
-func (r *TableRepositoryMemory) UpdateRevenue(tableID, price int, t time.Time) error {
+// UpdateRevenue charges the session started at the table for the time up
+// to t and returns it. The client of the session is left for the caller to
+// fill in, as the table may already be given up.
+func (r *TableRepositoryMemory) UpdateRevenue(tableID, price int, t time.Time) (Session, error) {
 	table, ok := r.tables[tableID]
 	if !ok {
-		return ErrTableNotFound
+		return Session{}, ErrTableNotFound
 	}
 
-	duration := t.Sub(table.StartTime)
-	durationInHours := duration.Minutes() / 60
-	priceCounter := int(math.Ceil(durationInHours))
+	paused := table.PausedUntil(t)
+	duration := t.Sub(table.StartTime) - paused
+	b := r.charge(table, price, t)
 
-	table.Revenue += priceCounter * price
+	table.Revenue += b.amount
+	table.Discount += b.discount
 	table.AllTime += duration
+	for band, bandAmount := range b.byBand {
+		table.BandRevenue[band] += bandAmount
+	}
+
+	session := Session{
+		TableID:  tableID,
+		Start:    table.StartTime,
+		End:      t,
+		Billed:   b.billed,
+		Amount:   b.amount,
+		Discount: b.discount,
+		Paused:   paused,
+	}
+
+	if b.packaged {
+		table.Package.Charged = true
+		session.Package = table.Package.Name
+	}
+	return session, nil
+}
+
+// bill is the price of a session. The split by band is of the time billed
+// as usual, before the discount; packaged marks a session with time in the
+// window of the package of the client.
+type bill struct {
+	billed   time.Duration
+	amount   int
+	discount int
+	byBand   map[string]int
+	packaged bool
+}
+
+// charge prices the session at the table for the time up to t, laying the
+// charged minutes on the intervals played between the breaks. The time in the window of a package costs the package price,
+// collected once; the rest is billed as usual and discounted.
+func (r *TableRepositoryMemory) charge(table *Table, price int, t time.Time) bill {
+	tablePrice := price
+	if table.Price != 0 {
+		tablePrice = table.Price
+	}
+
+	var b bill
+	played := table.PlayedUntil(t)
+
+	if p := table.Package; p != nil {
+		inside, outside := p.overflow(played)
+		if inside > 0 {
+			b.packaged = true
+			b.billed = inside
+			if !p.Charged {
+				b.amount = p.Price
+			}
+			played = outside
+		}
+	}
+
+	billed := r.billing.Billed(played.Duration())
+	gross, byBand := r.tariff.Amount(played, billed, price, tablePrice)
+	b.discount = r.discounts.Amount(played, billed, gross, table.Percent)
+	b.billed += billed
+	b.amount += gross - b.discount
+	b.byBand = byBand
+	return b
+}
+
+// Pause marks the client away from the table from t and returns the table.
+func (r *TableRepositoryMemory) Pause(clientName string, t time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for tableID, table := range r.tables {
+		if table.ClientName != clientName {
+			continue
+		}
+
+		if table.Paused() {
+			return tableID, ErrAlreadyPaused
+		}
+		table.Pauses = append(table.Pauses, Pause{Start: t})
+		return tableID, nil
+	}
+	return 0, ErrNotAtTable
+}
+
+// Resume ends the break of the client at t and returns the table.
+func (r *TableRepositoryMemory) Resume(clientName string, t time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for tableID, table := range r.tables {
+		if table.ClientName != clientName {
+			continue
+		}
+
+		if !table.Paused() {
+			return tableID, ErrNotPaused
+		}
+		table.Pauses[len(table.Pauses)-1].End = t
+		return tableID, nil
+	}
+	return 0, ErrNotAtTable
+}
 
+// PausedLonger returns the tables whose client has been away for longer
+// than limit by t, in the order the breaks started.
+func (r *TableRepositoryMemory) PausedLonger(limit time.Duration, t time.Time) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tableIDs []int
+	for tableID, table := range r.tables {
+		if table.ClientName != "" && table.Paused() && t.Sub(table.Pauses[len(table.Pauses)-1].Start) > limit {
+			tableIDs = append(tableIDs, tableID)
+		}
+	}
+
+	sort.Slice(tableIDs, func(i, j int) bool {
+		a, b := r.tables[tableIDs[i]].Pauses, r.tables[tableIDs[j]].Pauses
+		if start, other := a[len(a)-1].Start, b[len(b)-1].Start; !start.Equal(other) {
+			return start.Before(other)
+		}
+		return tableIDs[i] < tableIDs[j]
+	})
+	return tableIDs
+}
+
+// SetClientPackage gives the client a time package for the rest of the
+// visit, including the session in progress; nil takes it away. A client
+// may hold one package at a time.
+func (r *TableRepositoryMemory) SetClientPackage(clientName string, p *club.TimePackage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p == nil {
+		delete(r.packages, clientName)
+		return nil
+	}
+
+	if _, ok := r.packages[clientName]; ok {
+		return ErrPackageAssigned
+	}
+
+	bought := &Package{TimePackage: *p}
+	r.packages[clientName] = bought
+	for _, table := range r.tables {
+		if table.ClientName == clientName {
+			table.Package = bought
+		}
+	}
 	return nil
 }
 
+// SetClientDiscount gives the client a percentage off the sessions started
+// from now on; zero takes it away.
+func (r *TableRepositoryMemory) SetClientDiscount(clientName string, percent int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if percent == 0 {
+		delete(r.percents, clientName)
+		return
+	}
+	r.percents[clientName] = percent
+}
+
+// Due returns what the session at the table would cost if it ended at t.
+// The table may be a copy taken earlier in the session.
+func (r *TableRepositoryMemory) Due(table *Table, price int, t time.Time) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if table.ClientName == "" {
+		return 0
+	}
+	return r.charge(table, price, t).amount
+}
+
+// RunsOut reports whether the session at the table came to cost more than
+// the balance by t, and returns the last minute the balance still covers,
+// but not earlier than from unless from is zero.
+func (r *TableRepositoryMemory) RunsOut(tableID, price, balance int, from, t time.Time) (time.Time, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	table, ok := r.tables[tableID]
+	if !ok || table.ClientName == "" {
+		return time.Time{}, false
+	}
+
+	if r.charge(table, price, t).amount <= balance {
+		return time.Time{}, false
+	}
+
+	end := table.StartTime
+	if !from.IsZero() && from.After(end) {
+		if r.charge(table, price, from).amount > balance {
+			return from, true
+		}
+		end = from
+	}
+
+	for {
+		next := end.Add(time.Minute)
+		if r.charge(table, price, next).amount > balance {
+			return end, true
+		}
+		end = next
+	}
+}
+
 func (r *TableRepositoryMemory) Exists(clientName string) (int, bool) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -117,10 +367,182 @@ func (r *TableRepositoryMemory) CountEmptyTables() int {
 	return count
 }
 
-func NewMemoryRepo(maxTables int) *TableRepositoryMemory {
+// claimReservation refuses the table to anyone but the client who reserved
+// it for the moment. The client who reserved it takes up the reservation.
+func (r *TableRepositoryMemory) claimReservation(clientName string, tableID int, t time.Time) error {
+	for i, reservation := range r.reservations {
+		if reservation.TableID != tableID {
+			continue
+		}
+
+		if reservation.Client == clientName && t.Before(reservation.End()) {
+			r.reservations = append(r.reservations[:i], r.reservations[i+1:]...)
+			return nil
+		}
+
+		if reservation.Client != clientName && reservation.Holds(t) {
+			return ErrTableReserved
+		}
+	}
+	return nil
+}
+
+// Reserve books the table of the reservation, or the first table of its
+// category free for the whole period, and fills in the table. A client may
+// hold one reservation at a time.
+func (r *TableRepositoryMemory) Reserve(reservation *club.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.reservations {
+		if other.Client == reservation.Client {
+			return ErrReservationConflict
+		}
+	}
+
+	if reservation.TableID != 0 {
+		if reservation.TableID > r.maxTables {
+			return ErrTableOutOfRange
+		}
+		if !r.reservable(reservation.TableID, reservation) {
+			return ErrReservationConflict
+		}
+
+		r.reservations = append(r.reservations, reservation)
+		return nil
+	}
+
+	found := false
+	for tableID := 1; tableID <= r.maxTables; tableID++ {
+		if club.FindCategory(r.categories, tableID).Name != reservation.Category {
+			continue
+		}
+		found = true
+
+		if r.reservable(tableID, reservation) {
+			reservation.TableID = tableID
+			r.reservations = append(r.reservations, reservation)
+			return nil
+		}
+	}
+
+	if !found {
+		return ErrCategoryNotFound
+	}
+	return ErrReservationConflict
+}
+
+func (r *TableRepositoryMemory) reservable(tableID int, reservation *club.Reservation) bool {
+	for _, other := range r.reservations {
+		if other.TableID == tableID && other.Overlaps(reservation) {
+			return false
+		}
+	}
+	return true
+}
+
+// MoveReservation gives the client who came for the reserved table while
+// someone else still sits at it a free table of the same category, reserved
+// for the rest of the booking, and returns it. Without such a table the
+// booking stays where it is and is kept for the client to the end.
+func (r *TableRepositoryMemory) MoveReservation(clientName string, tableID int, t time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var reservation *club.Reservation
+	for _, other := range r.reservations {
+		if other.Client == clientName && other.TableID == tableID && t.Before(other.End()) {
+			reservation = other
+		}
+	}
+	if reservation == nil {
+		return 0, ErrReservationNotFound
+	}
+
+	category := club.FindCategory(r.categories, tableID).Name
+	for freeID := 1; freeID <= r.maxTables; freeID++ {
+		if freeID == tableID || club.FindCategory(r.categories, freeID).Name != category {
+			continue
+		}
+
+		if table, ok := r.tables[freeID]; ok && table.ClientName != "" {
+			continue
+		}
+
+		if r.reservable(freeID, reservation) {
+			reservation.TableID = freeID
+			return freeID, nil
+		}
+	}
+
+	reservation.Arrived = true
+	return 0, ErrPlaceIsBusy
+}
+
+func (r *TableRepositoryMemory) CancelReservation(clientName string) (*club.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, reservation := range r.reservations {
+		if reservation.Client == clientName {
+			r.reservations = append(r.reservations[:i], r.reservations[i+1:]...)
+			return reservation, nil
+		}
+	}
+	return nil, ErrReservationNotFound
+}
+
+// ReleaseExpired drops the reservations of the clients who did not come by
+// the deadline and returns them in the order of their deadlines. The
+// reservations of the clients who came but found the table taken are
+// dropped without being returned once they end.
+func (r *TableRepositoryMemory) ReleaseExpired(t time.Time) []*club.Reservation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var released []*club.Reservation
+	kept := r.reservations[:0]
+	for _, reservation := range r.reservations {
+		switch {
+		case reservation.Arrived && t.Before(reservation.End()):
+			kept = append(kept, reservation)
+		case reservation.Arrived:
+		case t.After(reservation.Deadline()):
+			released = append(released, reservation)
+		default:
+			kept = append(kept, reservation)
+		}
+	}
+	r.reservations = kept
+
+	sort.SliceStable(released, func(i, j int) bool {
+		return released[i].Deadline().Before(released[j].Deadline())
+	})
+	return released
+}
+
+func NewMemoryRepo(maxTables int, billing BillingStrategy, tariff *Tariff, discounts *Discounts, categories []club.TableCategory) *TableRepositoryMemory {
+	if billing == nil {
+		billing = BlockBilling{Block: time.Hour}
+	}
+
+	if tariff == nil {
+		tariff = NewTariff(nil)
+	}
+
+	if discounts == nil {
+		discounts = NewDiscounts(nil)
+	}
+
 	return &TableRepositoryMemory{
-		tables:    make(map[int]*Table),
-		maxTables: maxTables,
-		mu:        &sync.RWMutex{},
+		tables:     make(map[int]*Table),
+		maxTables:  maxTables,
+		billing:    billing,
+		tariff:     tariff,
+		discounts:  discounts,
+		categories: categories,
+		percents:   make(map[string]int),
+		packages:   make(map[string]*Package),
+		mu:         &sync.RWMutex{},
 	}
 }
@@ -0,0 +1,49 @@
+package table
+
+import "time"
+
+// EndReason tells how a session came to an end.
+type EndReason string
+
+const (
+	SessionLeft   EndReason = "left"
+	SessionMoved  EndReason = "moved"
+	SessionClosed EndReason = "closed"
+
+	// SessionBalance ends the session of a client whose prepaid balance
+	// has run out.
+	SessionBalance EndReason = "balance"
+
+	// SessionPaused ends the session of a client who was away from the
+	// table for longer than the club allows.
+	SessionPaused EndReason = "pause"
+)
+
+// Session is the stay of one client at one table, from taking the table to
+// giving it up. Amount is charged after Discount was taken off. Package
+// names the time package covering part of the session. Paused is the time
+// the client was away, not charged. QueuedIn marks a session the client got
+// from the queue.
+type Session struct {
+	Client   string
+	TableID  int
+	Start    time.Time
+	End      time.Time
+	Billed   time.Duration
+	Amount   int
+	Discount int
+	Package  string
+	Paused   time.Duration
+	QueuedIn bool
+	Reason   EndReason
+}
+
+// Duration is the time played, without the breaks.
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start) - s.Paused
+}
+
+// Gross is the price of the session before the discount.
+func (s Session) Gross() int {
+	return s.Amount + s.Discount
+}
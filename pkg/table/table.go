@@ -5,21 +5,148 @@ import (
 	"time"
 )
 
+// Table is a single place of the club. A zero Price means the table is
+// charged at the price of the club. Revenue is collected after discounts;
+// Discount adds up what was taken off, Percent and Package are the discount
+// and the time package of the client of the current session. Pauses are
+// the breaks of the client of the current session, not charged.
 type Table struct {
-	TableID    int
-	ClientName string
-	StartTime  time.Time
-	AllTime    time.Duration
-	Revenue    int
+	TableID     int
+	Category    string
+	Price       int
+	ClientName  string
+	StartTime   time.Time
+	AllTime     time.Duration
+	Revenue     int
+	Discount    int
+	Percent     int
+	Package     *Package
+	Pauses      []Pause
+	BandRevenue map[string]int
+}
+
+// Pause is a break the client of the session took at the table. End is
+// zero while the client is away.
+type Pause struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Interval is a stretch of a session the client played without a break.
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Intervals is the time played in a session, in time order.
+type Intervals []Interval
+
+// Duration adds up the time played.
+func (in Intervals) Duration() time.Duration {
+	var d time.Duration
+	for _, i := range in {
+		d += i.End.Sub(i.Start)
+	}
+	return d
+}
+
+// at returns the clock time offset into the time played. An offset past
+// the end is counted on from the end of the last interval.
+func (in Intervals) at(offset time.Duration) time.Time {
+	if len(in) == 0 {
+		return time.Time{}
+	}
+
+	for _, i := range in {
+		d := i.End.Sub(i.Start)
+		if offset < d {
+			return i.Start.Add(offset)
+		}
+		offset -= d
+	}
+	return in[len(in)-1].End.Add(offset)
+}
+
+// minute returns the clock time the i-th charged minute is priced at.
+// Minutes charged past the time played by rounding up are priced as the
+// last minute played.
+func (in Intervals) minute(i int) time.Time {
+	if last := int(in.Duration()/time.Minute) - 1; last >= 0 && i > last {
+		i = last
+	}
+	return in.at(time.Duration(i) * time.Minute)
+}
+
+// add appends the interval, joining it to the last one when they touch.
+func (in Intervals) add(i Interval) Intervals {
+	if n := len(in); n > 0 && in[n-1].End.Equal(i.Start) {
+		in[n-1].End = i.End
+		return in
+	}
+	return append(in, i)
+}
+
+// Paused reports whether the client of the table is away.
+func (t *Table) Paused() bool {
+	return len(t.Pauses) != 0 && t.Pauses[len(t.Pauses)-1].End.IsZero()
+}
+
+// PausedUntil adds up the breaks of the session up to at.
+func (t *Table) PausedUntil(at time.Time) time.Duration {
+	var paused time.Duration
+	for _, p := range t.Pauses {
+		end := p.End
+		if end.IsZero() || end.After(at) {
+			end = at
+		}
+		if end.After(p.Start) {
+			paused += end.Sub(p.Start)
+		}
+	}
+	return paused
+}
+
+// PlayedUntil splits the session up to at into the intervals between its
+// breaks. The session is never empty: it starts with its first interval.
+func (t *Table) PlayedUntil(at time.Time) Intervals {
+	played := Intervals{}
+	start := t.StartTime
+	for _, p := range t.Pauses {
+		if !p.Start.Before(at) {
+			break
+		}
+		if p.Start.After(start) || len(played) == 0 {
+			played = append(played, Interval{Start: start, End: p.Start})
+		}
+		if p.End.IsZero() || !p.End.Before(at) {
+			return played
+		}
+		start = p.End
+	}
+	return append(played, Interval{Start: start, End: at})
+}
+
+// Copy returns a copy of the table that later changes of the session do not
+// affect.
+func (t *Table) Copy() Table {
+	c := *t
+	c.Pauses = append([]Pause(nil), t.Pauses...)
+	if t.Package != nil {
+		p := *t.Package
+		c.Package = &p
+	}
+	c.BandRevenue = nil
+	return c
 }
 
 func NewTable(username string, tableID int, time time.Time) *Table {
 	return &Table{
-		TableID:    tableID,
-		ClientName: username,
-		StartTime:  time,
-		AllTime:    0,
-		Revenue:    0,
+		TableID:     tableID,
+		ClientName:  username,
+		StartTime:   time,
+		AllTime:     0,
+		Revenue:     0,
+		BandRevenue: make(map[string]int),
 	}
 }
 
@@ -0,0 +1,58 @@
+package table
+
+import (
+	"time"
+
+	"github.com/apartapatia/computer_club_assistant/pkg/club"
+)
+
+// BaseBand names the charged time not covered by any tariff band.
+const BaseBand = "base"
+
+// Tariff prices charged time by the time-of-day bands of the club. Time
+// outside every band is charged at the price of the table.
+type Tariff struct {
+	bands []club.TariffBand
+}
+
+// Amount prices the time charged for a session played in the given
+// intervals minute by minute and returns the total together with its split by band. Band prices
+// are set for the club price and scaled for tables priced differently.
+func (tf *Tariff) Amount(played Intervals, billed time.Duration, clubPrice, tablePrice int) (int, map[string]int) {
+	minutes := make(map[string]int)
+	prices := map[string]int{BaseBand: clubPrice}
+
+	for i := 0; i < int(billed/time.Minute); i++ {
+		name, bandPrice := tf.bandAt(played.minute(i))
+		if bandPrice == 0 {
+			name, bandPrice = BaseBand, clubPrice
+		}
+		minutes[name]++
+		prices[name] = bandPrice
+	}
+
+	total := 0
+	byBand := make(map[string]int, len(minutes))
+	for name, m := range minutes {
+		amount := ceilDiv(prices[name]*tablePrice*m, clubPrice*60)
+		byBand[name] = amount
+		total += amount
+	}
+
+	return total, byBand
+}
+
+func (tf *Tariff) bandAt(t time.Time) (string, int) {
+	for _, band := range tf.bands {
+		if band.Contains(t) {
+			return band.Name, band.Price
+		}
+	}
+	return "", 0
+}
+
+func NewTariff(bands []club.TariffBand) *Tariff {
+	return &Tariff{
+		bands: bands,
+	}
+}